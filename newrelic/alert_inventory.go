@@ -0,0 +1,352 @@
+package newrelic
+
+import (
+	"fmt"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"net/url"
+	"strconv"
+)
+
+// AlertInventoryMetrics is a list containing the available alert policy and coverage metrics and their properties.
+var AlertInventoryMetrics = []Metric{
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("policy"),
+			plugin.NamespaceElement{
+				Name:        "policy_id",
+				Description: "Alert policy id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("conditions"),
+			plugin.NewNamespaceElement("count"),
+		},
+		Type: "policy",
+		Path: "ConditionCount",
+		Unit: "int",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("policy"),
+			plugin.NamespaceElement{
+				Name:        "policy_id",
+				Description: "Alert policy id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("conditions"),
+			plugin.NewNamespaceElement("enabled"),
+		},
+		Type: "policy",
+		Path: "EnabledConditionCount",
+		Unit: "int",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("coverage"),
+			plugin.NewNamespaceElement("covered"),
+		},
+		Type: "application",
+		Path: "Covered",
+		Unit: "int",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("coverage"),
+			plugin.NewNamespaceElement("conditions"),
+		},
+		Type: "application",
+		Path: "EnabledConditionCount",
+		Unit: "int",
+	},
+}
+
+// applicationConditionTypes are the alert condition types whose entities are APM applications.
+var applicationConditionTypes = map[string]bool{
+	"apm_app_metric": true,
+	"apm_jvm_metric": true,
+}
+
+// AlertPolicy is an alert policy.
+type AlertPolicy struct {
+	ID                 int    `json:"id"`
+	Name               string `json:"name"`
+	IncidentPreference string `json:"incident_preference"`
+}
+
+// AlertCondition is an alert condition belonging to an alert policy.
+type AlertCondition struct {
+	ID       int      `json:"id"`
+	Type     string   `json:"type"`
+	Name     string   `json:"name"`
+	Enabled  bool     `json:"enabled"`
+	Entities []string `json:"entities"`
+}
+
+// AlertInventoryClient is the interface every alert inventory client needs to implement.
+type AlertInventoryClient interface {
+	GetAlertPolicies() ([]AlertPolicy, error)
+	GetAlertConditions(int) ([]AlertCondition, error)
+}
+
+// AlertInventoryClientImpl is a real implementation of an AlertInventoryClient.
+type AlertInventoryClientImpl struct {
+	APIKey string
+}
+
+// GetAlertPolicies fetches all alert policies from New Relic.
+func (ac *AlertInventoryClientImpl) GetAlertPolicies() ([]AlertPolicy, error) {
+	r := newRESTClient(ac.APIKey)
+
+	policies := []AlertPolicy{}
+	err := r.getPages("alerts_policies.json", url.Values{}, func(get func(interface{}) error) (int, error) {
+		resp := struct {
+			Policies []AlertPolicy `json:"policies"`
+		}{}
+
+		if err := get(&resp); err != nil {
+			return 0, err
+		}
+
+		policies = append(policies, resp.Policies...)
+
+		return len(resp.Policies), nil
+	})
+
+	return policies, err
+}
+
+// GetAlertConditions fetches all alert conditions of an alert policy from New Relic.
+func (ac *AlertInventoryClientImpl) GetAlertConditions(policyID int) ([]AlertCondition, error) {
+	r := newRESTClient(ac.APIKey)
+
+	params := url.Values{
+		"policy_id": {strconv.Itoa(policyID)},
+	}
+
+	conditions := []AlertCondition{}
+	err := r.getPages("alerts_conditions.json", params, func(get func(interface{}) error) (int, error) {
+		resp := struct {
+			Conditions []AlertCondition `json:"conditions"`
+		}{}
+
+		if err := get(&resp); err != nil {
+			return 0, err
+		}
+
+		conditions = append(conditions, resp.Conditions...)
+
+		return len(resp.Conditions), nil
+	})
+
+	return conditions, err
+}
+
+// AlertInventory represents the alert policies and conditions part of New Relic.
+type AlertInventory struct {
+	AlertInventoryClient AlertInventoryClient
+	Applications         *Applications
+	Workers              *Workers
+}
+
+// NewAlertInventory creates and returns a new AlertInventory object with a configured AlertInventoryClient, a shared
+// applications lookup and the workers of the collection, if any.
+func NewAlertInventory(apiKey string, apps *Applications, workers *Workers) Service {
+	return &AlertInventory{
+		AlertInventoryClient: &AlertInventoryClientImpl{
+			APIKey: apiKey,
+		},
+		Applications: apps,
+		Workers:      workers,
+	}
+}
+
+// GetMetricTypes returns the available alert policy and coverage metric types.
+func (ai *AlertInventory) GetMetricTypes(_ plugin.Config) ([]plugin.Metric, error) {
+	ns := plugin.NewNamespace("inteleon", "newrelic", "alerts")
+
+	return metricTypes(ns, AlertInventoryMetrics)
+}
+
+// CollectMetrics fetches the requested alert policy and coverage metrics and returns them.
+func (ai *AlertInventory) CollectMetrics(metrics []plugin.Metric) ([]plugin.Metric, error) {
+	collectedMetrics := []plugin.Metric{}
+
+	if len(metrics) == 0 {
+		return collectedMetrics, fmt.Errorf("List of metrics is empty")
+	}
+
+	var policies []AlertPolicy
+	conditions := map[int][]AlertCondition{}
+	for i, m := range metrics {
+		if m.Namespace.Element(2).Value != "alerts" {
+			continue
+		}
+
+		metricType := m.Namespace.Element(3).Value
+		if metricType != "policy" && metricType != "application" {
+			continue
+		}
+
+		if policies == nil {
+			// Policies and conditions missing, fetching...
+			fetchedPolicies, err := ai.AlertInventoryClient.GetAlertPolicies()
+			if err != nil {
+				return collectedMetrics, err
+			}
+
+			// Conditions missing, fetching them concurrently per policy...
+			fetchedConditions := make([][]AlertCondition, len(fetchedPolicies))
+			errs := serviceWorkers(ai.Workers, metrics).parallel(len(fetchedPolicies), func(j int) error {
+				var err error
+				fetchedConditions[j], err = ai.AlertInventoryClient.GetAlertConditions(fetchedPolicies[j].ID)
+
+				return err
+			})
+
+			if err := firstError(errs); err != nil {
+				return collectedMetrics, err
+			}
+
+			for j, policy := range fetchedPolicies {
+				conditions[policy.ID] = fetchedConditions[j]
+			}
+
+			policies = fetchedPolicies
+		}
+
+		var typeMetrics []plugin.Metric
+		var err error
+		if metricType == "policy" {
+			typeMetrics = policyMetrics(metrics[i], policies, conditions)
+		} else {
+			typeMetrics, err = ai.coverageMetrics(metrics[i], policies, conditions)
+		}
+
+		if err != nil {
+			return collectedMetrics, err
+		}
+
+		for j := range typeMetrics {
+			collectedMetrics = append(collectedMetrics, typeMetrics[j])
+		}
+	}
+
+	return collectedMetrics, nil
+}
+
+// policyMetrics reports the number of conditions of the requested policies.
+func policyMetrics(metric plugin.Metric, policies []AlertPolicy, conditions map[int][]AlertCondition) []plugin.Metric {
+	policiesMetrics := []plugin.Metric{}
+
+	policyID := metric.Namespace.Element(4).Value
+	for _, policy := range policies {
+		if policyID != "*" && policyID != strconv.Itoa(policy.ID) && policyID != policy.Name {
+			continue
+		}
+
+		policyMetric := metric
+		if policyID == "*" {
+			policyMetric = withElementValue(policyMetric, 4, strconv.Itoa(policy.ID))
+		}
+
+		enabledConditions := 0
+		for _, condition := range conditions[policy.ID] {
+			if condition.Enabled {
+				enabledConditions++
+			}
+		}
+
+		populatedMetric, err := populateMetric(
+			policyMetric,
+			map[string]interface{}{
+				"ConditionCount":        len(conditions[policy.ID]),
+				"EnabledConditionCount": enabledConditions,
+			},
+			map[string]string{
+				"policy_id":           strconv.Itoa(policy.ID),
+				"policy_name":         policy.Name,
+				"incident_preference": policy.IncidentPreference,
+			},
+		)
+		if err != nil {
+			// Metric not found, skip reporting it and continue execution.
+			continue
+		}
+
+		policiesMetrics = append(policiesMetrics, populatedMetric)
+	}
+
+	return policiesMetrics
+}
+
+// coverageMetrics reports whether the requested applications are covered by any enabled alert condition.
+func (ai *AlertInventory) coverageMetrics(metric plugin.Metric, policies []AlertPolicy, conditions map[int][]AlertCondition) ([]plugin.Metric, error) {
+	coverageMetrics := []plugin.Metric{}
+
+	if ai.Applications == nil {
+		return coverageMetrics, fmt.Errorf("Unable to check alert coverage, no applications lookup configured")
+	}
+
+	enabledConditions := map[string]int{}
+	for _, policy := range policies {
+		for _, condition := range conditions[policy.ID] {
+			if !condition.Enabled || !applicationConditionTypes[condition.Type] {
+				continue
+			}
+
+			for _, entity := range condition.Entities {
+				enabledConditions[entity]++
+			}
+		}
+	}
+
+	apps, err := ai.Applications.List()
+	if err != nil {
+		return coverageMetrics, err
+	}
+
+	appID := metric.Namespace.Element(4).Value
+	for i := range apps {
+		if appID != "*" && appID != strconv.Itoa(apps[i].ID) && appID != apps[i].Name {
+			continue
+		}
+
+		appMetric := metric
+		if appID == "*" {
+			appMetric = withElementValue(appMetric, 4, strconv.Itoa(apps[i].ID))
+		}
+
+		covered := 0
+		if enabledConditions[strconv.Itoa(apps[i].ID)] > 0 {
+			covered = 1
+		}
+
+		populatedMetric, err := populateMetric(
+			appMetric,
+			map[string]interface{}{
+				"Covered":               covered,
+				"EnabledConditionCount": enabledConditions[strconv.Itoa(apps[i].ID)],
+			},
+			applicationTags(&apps[i]),
+		)
+		if err != nil {
+			// Metric not found, skip reporting it and continue execution.
+			continue
+		}
+
+		coverageMetrics = append(coverageMetrics, populatedMetric)
+	}
+
+	return coverageMetrics, nil
+}
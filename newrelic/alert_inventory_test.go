@@ -0,0 +1,184 @@
+package newrelic_test
+
+import (
+	"fmt"
+	"github.com/inteleon/snap-plugin-collector-newrelic/newrelic"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type alertInventoryClientTestImpl struct {
+	mu sync.Mutex
+
+	policiesCalls      int
+	conditionPolicyIDs []int
+}
+
+func (a *alertInventoryClientTestImpl) GetAlertPolicies() ([]newrelic.AlertPolicy, error) {
+	a.policiesCalls++
+
+	return []newrelic.AlertPolicy{
+		{
+			ID:                 10,
+			Name:               "Production",
+			IncidentPreference: "PER_POLICY",
+		},
+		{
+			ID:   20,
+			Name: "Staging",
+		},
+	}, nil
+}
+
+func (a *alertInventoryClientTestImpl) GetAlertConditions(policyID int) ([]newrelic.AlertCondition, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.conditionPolicyIDs = append(a.conditionPolicyIDs, policyID)
+
+	if policyID != 10 {
+		return []newrelic.AlertCondition{
+			{
+				ID:       200,
+				Type:     "apm_app_metric",
+				Enabled:  false,
+				Entities: []string{"1234"},
+			},
+		}, nil
+	}
+
+	return []newrelic.AlertCondition{
+		{
+			ID:       100,
+			Type:     "apm_app_metric",
+			Enabled:  true,
+			Entities: []string{"1337"},
+		},
+		{
+			ID:       101,
+			Type:     "browser_metric",
+			Enabled:  true,
+			Entities: []string{"1234"},
+		},
+		{
+			ID:       102,
+			Type:     "apm_app_metric",
+			Enabled:  false,
+			Entities: []string{"1234"},
+		},
+	}, nil
+}
+
+func TestGetAlertInventoryMetricTypesSuccess(t *testing.T) {
+	ai := &newrelic.AlertInventory{}
+
+	metrics, err := ai.GetMetricTypes(plugin.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLen := len(newrelic.AlertInventoryMetrics)
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	for i, m := range metrics {
+		expectedNS := fmt.Sprintf("inteleon/newrelic/alerts/%s", strings.Join(newrelic.AlertInventoryMetrics[i].Namespace.Strings(), "/"))
+		ns := strings.Join(m.Namespace.Strings(), "/")
+
+		if ns != expectedNS {
+			t.Fatal("expected", expectedNS, "got", ns)
+		}
+	}
+}
+
+func TestCollectAlertInventoryMetricsSuccess(t *testing.T) {
+	inventoryClient := &alertInventoryClientTestImpl{}
+
+	ai := &newrelic.AlertInventory{
+		AlertInventoryClient: inventoryClient,
+		Applications:         newrelic.NewApplications(&apmClientTestImpl{}),
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "alerts", "policy", "*", "conditions", "count"),
+			Tags: map[string]string{
+				"Type": "policy",
+				"Path": "ConditionCount",
+				"Unit": "int",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "alerts", "policy", "Production", "conditions", "enabled"),
+			Tags: map[string]string{
+				"Type": "policy",
+				"Path": "EnabledConditionCount",
+				"Unit": "int",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "alerts", "application", "*", "coverage", "covered"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Path": "Covered",
+				"Unit": "int",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "alerts", "violations", "policy", "*", "open", "count"),
+			Tags: map[string]string{
+				"Type": "violations_policy",
+				"Path": "Count",
+				"Unit": "int",
+			},
+		},
+	}
+
+	ret, err := ai.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 5 {
+		t.Fatal("expected", 5, "got", len(ret))
+	}
+
+	expected := []struct {
+		id   string
+		data int
+	}{
+		{"10", 3},
+		{"20", 1},
+		{"Production", 2},
+		{"1337", 1},
+		{"1234", 0},
+	}
+	for i, e := range expected {
+		if ret[i].Namespace.Element(4).Value != e.id {
+			t.Fatal("expected", e.id, "got", ret[i].Namespace.Element(4).Value)
+		}
+
+		if ret[i].Data.(int) != e.data {
+			t.Fatal("expected", e.data, "got", ret[i].Data.(int))
+		}
+	}
+
+	if ret[0].Tags["policy_name"] != "Production" {
+		t.Fatal("expected", "Production", "got", ret[0].Tags["policy_name"])
+	}
+
+	if ret[4].Tags["app_name"] != "leet" {
+		t.Fatal("expected", "leet", "got", ret[4].Tags["app_name"])
+	}
+
+	if inventoryClient.policiesCalls != 1 {
+		t.Fatal("expected", 1, "got", inventoryClient.policiesCalls)
+	}
+
+	if len(inventoryClient.conditionPolicyIDs) != 2 {
+		t.Fatal("expected", 2, "got", len(inventoryClient.conditionPolicyIDs))
+	}
+}
@@ -0,0 +1,327 @@
+package newrelic
+
+import (
+	"fmt"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AlertsMetrics is a list containing the available alert violation and incident metrics and their properties.
+var AlertsMetrics = []Metric{
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("violations"),
+			plugin.NewNamespaceElement("policy"),
+			plugin.NamespaceElement{
+				Name:        "policy_id",
+				Description: "Alert policy id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("open"),
+			plugin.NewNamespaceElement("count"),
+		},
+		Type: "violations_policy",
+		Path: "Count",
+		Unit: "int",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("violations"),
+			plugin.NewNamespaceElement("condition"),
+			plugin.NamespaceElement{
+				Name:        "condition_id",
+				Description: "Alert condition id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("open"),
+			plugin.NewNamespaceElement("count"),
+		},
+		Type: "violations_condition",
+		Path: "Count",
+		Unit: "int",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("violations"),
+			plugin.NewNamespaceElement("priority"),
+			plugin.NamespaceElement{
+				Name:        "priority",
+				Description: "Violation priority (critical or warning)",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("open"),
+			plugin.NewNamespaceElement("count"),
+		},
+		Type: "violations_priority",
+		Path: "Count",
+		Unit: "int",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("incidents"),
+			plugin.NewNamespaceElement("open"),
+			plugin.NewNamespaceElement("count"),
+		},
+		Type: "incidents",
+		Path: "Count",
+		Unit: "int",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("incidents"),
+			plugin.NewNamespaceElement("oldest_open"),
+			plugin.NewNamespaceElement("duration"),
+		},
+		Type: "incidents",
+		Path: "OldestOpenDuration",
+		Unit: "int",
+	},
+}
+
+// AlertViolationLinks are the ids of the objects related to an alert violation.
+type AlertViolationLinks struct {
+	PolicyID    int `json:"policy_id"`
+	ConditionID int `json:"condition_id"`
+	IncidentID  int `json:"incident_id"`
+}
+
+// AlertViolation is a violation of an alert condition.
+type AlertViolation struct {
+	ID            int                 `json:"id"`
+	Label         string              `json:"label"`
+	Duration      int                 `json:"duration"`
+	PolicyName    string              `json:"policy_name"`
+	ConditionName string              `json:"condition_name"`
+	Priority      string              `json:"priority"`
+	OpenedAt      int64               `json:"opened_at"`
+	Links         AlertViolationLinks `json:"links"`
+}
+
+// AlertIncidentLinks are the ids of the objects related to an alert incident.
+type AlertIncidentLinks struct {
+	Violations []int `json:"violations"`
+	PolicyID   int   `json:"policy_id"`
+}
+
+// AlertIncident is an alert incident, grouping one or more violations.
+type AlertIncident struct {
+	ID                 int                `json:"id"`
+	OpenedAt           int64              `json:"opened_at"`
+	ClosedAt           int64              `json:"closed_at"`
+	IncidentPreference string             `json:"incident_preference"`
+	Links              AlertIncidentLinks `json:"links"`
+}
+
+// AlertsClient is the interface every alerts client needs to implement.
+type AlertsClient interface {
+	GetOpenViolations() ([]AlertViolation, error)
+	GetOpenIncidents() ([]AlertIncident, error)
+}
+
+// AlertsClientImpl is a real implementation of an AlertsClient.
+type AlertsClientImpl struct {
+	APIKey string
+}
+
+// GetOpenViolations fetches all open alert violations from New Relic.
+func (ac *AlertsClientImpl) GetOpenViolations() ([]AlertViolation, error) {
+	r := newRESTClient(ac.APIKey)
+
+	violations := []AlertViolation{}
+	err := r.getPages("alerts_violations.json", url.Values{"only_open": {"true"}}, func(get func(interface{}) error) (int, error) {
+		resp := struct {
+			Violations []AlertViolation `json:"violations"`
+		}{}
+
+		if err := get(&resp); err != nil {
+			return 0, err
+		}
+
+		violations = append(violations, resp.Violations...)
+
+		return len(resp.Violations), nil
+	})
+
+	return violations, err
+}
+
+// GetOpenIncidents fetches all open alert incidents from New Relic.
+func (ac *AlertsClientImpl) GetOpenIncidents() ([]AlertIncident, error) {
+	r := newRESTClient(ac.APIKey)
+
+	incidents := []AlertIncident{}
+	err := r.getPages("alerts_incidents.json", url.Values{"only_open": {"true"}}, func(get func(interface{}) error) (int, error) {
+		resp := struct {
+			Incidents []AlertIncident `json:"incidents"`
+		}{}
+
+		if err := get(&resp); err != nil {
+			return 0, err
+		}
+
+		incidents = append(incidents, resp.Incidents...)
+
+		return len(resp.Incidents), nil
+	})
+
+	return incidents, err
+}
+
+// Alerts represents the alert violations and incidents part of New Relic.
+type Alerts struct {
+	AlertsClient AlertsClient
+}
+
+// NewAlerts creates and returns a new Alerts object with a configured AlertsClient.
+func NewAlerts(apiKey string) Service {
+	return &Alerts{
+		AlertsClient: &AlertsClientImpl{
+			APIKey: apiKey,
+		},
+	}
+}
+
+// GetMetricTypes returns the available alert violation and incident metric types.
+func (a *Alerts) GetMetricTypes(_ plugin.Config) ([]plugin.Metric, error) {
+	ns := plugin.NewNamespace("inteleon", "newrelic", "alerts")
+
+	return metricTypes(ns, AlertsMetrics)
+}
+
+// CollectMetrics fetches the requested alert violation and incident metrics and returns them.
+func (a *Alerts) CollectMetrics(metrics []plugin.Metric) ([]plugin.Metric, error) {
+	collectedMetrics := []plugin.Metric{}
+
+	if len(metrics) == 0 {
+		return collectedMetrics, fmt.Errorf("List of metrics is empty")
+	}
+
+	var violations []AlertViolation
+	var incidents []AlertIncident
+	for i, m := range metrics {
+		if m.Namespace.Element(2).Value != "alerts" {
+			continue
+		}
+
+		switch m.Namespace.Element(3).Value {
+		case "violations":
+			if violations == nil {
+				// Violations missing, fetching...
+				fetchedViolations, err := a.AlertsClient.GetOpenViolations()
+				if err != nil {
+					return collectedMetrics, err
+				}
+
+				violations = fetchedViolations
+			}
+
+			for _, vm := range violationMetrics(metrics[i], violations) {
+				collectedMetrics = append(collectedMetrics, vm)
+			}
+
+			break
+		case "incidents":
+			if incidents == nil {
+				// Incidents missing, fetching...
+				fetchedIncidents, err := a.AlertsClient.GetOpenIncidents()
+				if err != nil {
+					return collectedMetrics, err
+				}
+
+				incidents = fetchedIncidents
+			}
+
+			oldestOpenDuration := 0
+			for _, incident := range incidents {
+				openFor := int(time.Since(time.Unix(0, incident.OpenedAt*int64(time.Millisecond))).Seconds())
+				if openFor > oldestOpenDuration {
+					oldestOpenDuration = openFor
+				}
+			}
+
+			populatedMetric, err := populateMetric(
+				metrics[i],
+				map[string]interface{}{
+					"Count":              len(incidents),
+					"OldestOpenDuration": oldestOpenDuration,
+				},
+				map[string]string{},
+			)
+			if err != nil {
+				// Metric not found, skip reporting it and continue execution.
+				continue
+			}
+
+			collectedMetrics = append(collectedMetrics, populatedMetric)
+
+			break
+		}
+	}
+
+	return collectedMetrics, nil
+}
+
+// violationMetrics counts the open violations per policy, condition or priority, depending on the metric type.
+// A wildcard reports every group with open violations, a specific group is reported even without open violations.
+func violationMetrics(metric plugin.Metric, violations []AlertViolation) []plugin.Metric {
+	groupMetrics := []plugin.Metric{}
+
+	counts := map[string]int{}
+	tags := map[string]map[string]string{}
+	for _, v := range violations {
+		var group string
+		groupTags := map[string]string{}
+
+		switch metric.Tags["Type"] {
+		case "violations_policy":
+			group = strconv.Itoa(v.Links.PolicyID)
+			groupTags["policy_name"] = v.PolicyName
+			break
+		case "violations_condition":
+			group = strconv.Itoa(v.Links.ConditionID)
+			groupTags["policy_name"] = v.PolicyName
+			groupTags["condition_name"] = v.ConditionName
+			break
+		case "violations_priority":
+			group = strings.ToLower(v.Priority)
+			break
+		}
+
+		counts[group]++
+		tags[group] = groupTags
+	}
+
+	requestedGroup := strings.ToLower(metric.Namespace.Element(5).Value)
+
+	groups := []string{}
+	if requestedGroup == "*" {
+		for group := range counts {
+			groups = append(groups, group)
+		}
+
+		sort.Strings(groups)
+	} else {
+		groups = append(groups, requestedGroup)
+	}
+
+	for _, group := range groups {
+		groupMetric := metric
+		if requestedGroup == "*" {
+			groupMetric = withElementValue(groupMetric, 5, group)
+		}
+
+		populatedMetric, err := populateMetric(groupMetric, map[string]interface{}{"Count": counts[group]}, tags[group])
+		if err != nil {
+			// Metric not found, skip reporting it and continue execution.
+			continue
+		}
+
+		groupMetrics = append(groupMetrics, populatedMetric)
+	}
+
+	return groupMetrics
+}
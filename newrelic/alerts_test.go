@@ -0,0 +1,208 @@
+package newrelic_test
+
+import (
+	"fmt"
+	"github.com/inteleon/snap-plugin-collector-newrelic/newrelic"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"strings"
+	"testing"
+	"time"
+)
+
+type alertsClientTestImpl struct {
+	violationsCalls int
+	incidentsCalls  int
+}
+
+func (a *alertsClientTestImpl) GetOpenViolations() ([]newrelic.AlertViolation, error) {
+	a.violationsCalls++
+
+	return []newrelic.AlertViolation{
+		{
+			ID:            1,
+			PolicyName:    "Production",
+			ConditionName: "High error rate",
+			Priority:      "Critical",
+			Links: newrelic.AlertViolationLinks{
+				PolicyID:    10,
+				ConditionID: 100,
+			},
+		},
+		{
+			ID:            2,
+			PolicyName:    "Production",
+			ConditionName: "Slow response",
+			Priority:      "Warning",
+			Links: newrelic.AlertViolationLinks{
+				PolicyID:    10,
+				ConditionID: 101,
+			},
+		},
+		{
+			ID:            3,
+			PolicyName:    "Staging",
+			ConditionName: "High error rate",
+			Priority:      "Critical",
+			Links: newrelic.AlertViolationLinks{
+				PolicyID:    20,
+				ConditionID: 200,
+			},
+		},
+	}, nil
+}
+
+func (a *alertsClientTestImpl) GetOpenIncidents() ([]newrelic.AlertIncident, error) {
+	a.incidentsCalls++
+
+	return []newrelic.AlertIncident{
+		{
+			ID:       1,
+			OpenedAt: time.Now().Add(-10*time.Minute).UnixNano() / int64(time.Millisecond),
+		},
+		{
+			ID:       2,
+			OpenedAt: time.Now().Add(-2*time.Hour).UnixNano() / int64(time.Millisecond),
+		},
+	}, nil
+}
+
+func TestGetAlertsMetricTypesSuccess(t *testing.T) {
+	a := &newrelic.Alerts{}
+
+	metrics, err := a.GetMetricTypes(plugin.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLen := len(newrelic.AlertsMetrics)
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	for i, m := range metrics {
+		expectedNS := fmt.Sprintf("inteleon/newrelic/alerts/%s", strings.Join(newrelic.AlertsMetrics[i].Namespace.Strings(), "/"))
+		ns := strings.Join(m.Namespace.Strings(), "/")
+
+		if ns != expectedNS {
+			t.Fatal("expected", expectedNS, "got", ns)
+		}
+	}
+}
+
+func TestCollectAlertsViolationMetricsSuccess(t *testing.T) {
+	alertsClient := &alertsClientTestImpl{}
+
+	a := &newrelic.Alerts{
+		AlertsClient: alertsClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "alerts", "violations", "policy", "*", "open", "count"),
+			Tags: map[string]string{
+				"Type": "violations_policy",
+				"Path": "Count",
+				"Unit": "int",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "alerts", "violations", "condition", "30", "open", "count"),
+			Tags: map[string]string{
+				"Type": "violations_condition",
+				"Path": "Count",
+				"Unit": "int",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "alerts", "violations", "priority", "critical", "open", "count"),
+			Tags: map[string]string{
+				"Type": "violations_priority",
+				"Path": "Count",
+				"Unit": "int",
+			},
+		},
+	}
+
+	ret, err := a.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 4 {
+		t.Fatal("expected", 4, "got", len(ret))
+	}
+
+	expected := []struct {
+		group string
+		count int
+	}{
+		{"10", 2},
+		{"20", 1},
+		{"30", 0},
+		{"critical", 2},
+	}
+	for i, e := range expected {
+		if ret[i].Namespace.Element(5).Value != e.group {
+			t.Fatal("expected", e.group, "got", ret[i].Namespace.Element(5).Value)
+		}
+
+		if ret[i].Data.(int) != e.count {
+			t.Fatal("expected", e.count, "got", ret[i].Data.(int))
+		}
+	}
+
+	if ret[0].Tags["policy_name"] != "Production" {
+		t.Fatal("expected", "Production", "got", ret[0].Tags["policy_name"])
+	}
+
+	if alertsClient.violationsCalls != 1 {
+		t.Fatal("expected", 1, "got", alertsClient.violationsCalls)
+	}
+
+	if alertsClient.incidentsCalls != 0 {
+		t.Fatal("expected", 0, "got", alertsClient.incidentsCalls)
+	}
+}
+
+func TestCollectAlertsIncidentMetricsSuccess(t *testing.T) {
+	a := &newrelic.Alerts{
+		AlertsClient: &alertsClientTestImpl{},
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "alerts", "incidents", "open", "count"),
+			Tags: map[string]string{
+				"Type": "incidents",
+				"Path": "Count",
+				"Unit": "int",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "alerts", "incidents", "oldest_open", "duration"),
+			Tags: map[string]string{
+				"Type": "incidents",
+				"Path": "OldestOpenDuration",
+				"Unit": "int",
+			},
+		},
+	}
+
+	ret, err := a.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 2 {
+		t.Fatal("expected", 2, "got", len(ret))
+	}
+
+	if ret[0].Data.(int) != 2 {
+		t.Fatal("expected", 2, "got", ret[0].Data.(int))
+	}
+
+	oldestOpenDuration := ret[1].Data.(int)
+	if oldestOpenDuration < 7200 || oldestOpenDuration > 7260 {
+		t.Fatal("expected", 7200, "got", oldestOpenDuration)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"github.com/fatih/structs"
 	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
 	nr "github.com/yfronto/newrelic"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // APMMetrics is a list containing the available APM metrics and their properties.
@@ -228,11 +231,251 @@ var APMMetrics = []Metric{
 		Path: "EndUserSummary/ApdexScore",
 		Unit: "float",
 	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("deployment"),
+			plugin.NewNamespaceElement("seconds_since"),
+		},
+		Type: "deployment",
+		Path: "SecondsSince",
+		Unit: "int",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "host_id",
+				Description: "Application host id or hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("response_time"),
+		},
+		Type: "host",
+		Path: "ApplicationSummary/ResponseTime",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "host_id",
+				Description: "Application host id or hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("throughput"),
+		},
+		Type: "host",
+		Path: "ApplicationSummary/Throughput",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "host_id",
+				Description: "Application host id or hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("error_rate"),
+		},
+		Type: "host",
+		Path: "ApplicationSummary/ErrorRate",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "host_id",
+				Description: "Application host id or hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("apdex_target"),
+		},
+		Type: "host",
+		Path: "ApplicationSummary/ApdexTarget",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "host_id",
+				Description: "Application host id or hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("apdex_score"),
+		},
+		Type: "host",
+		Path: "ApplicationSummary/ApdexScore",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("instance"),
+			plugin.NamespaceElement{
+				Name:        "instance_id",
+				Description: "Application instance id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("response_time"),
+		},
+		Type: "instance",
+		Path: "ApplicationSummary/ResponseTime",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("instance"),
+			plugin.NamespaceElement{
+				Name:        "instance_id",
+				Description: "Application instance id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("throughput"),
+		},
+		Type: "instance",
+		Path: "ApplicationSummary/Throughput",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("instance"),
+			plugin.NamespaceElement{
+				Name:        "instance_id",
+				Description: "Application instance id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("error_rate"),
+		},
+		Type: "instance",
+		Path: "ApplicationSummary/ErrorRate",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("instance"),
+			plugin.NamespaceElement{
+				Name:        "instance_id",
+				Description: "Application instance id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("apdex_target"),
+		},
+		Type: "instance",
+		Path: "ApplicationSummary/ApdexTarget",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("instance"),
+			plugin.NamespaceElement{
+				Name:        "instance_id",
+				Description: "Application instance id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("apdex_score"),
+		},
+		Type: "instance",
+		Path: "ApplicationSummary/ApdexScore",
+		Unit: "float",
+	},
+}
+
+// Deployment is a deployment marker recorded for an APM application.
+type Deployment struct {
+	ID          int       `json:"id"`
+	Revision    string    `json:"revision"`
+	Changelog   string    `json:"changelog"`
+	Description string    `json:"description"`
+	User        string    `json:"user"`
+	Timestamp   time.Time `json:"timestamp"`
 }
 
 // APMClient is the interface every AMP client needs to implement.
 type APMClient interface {
 	GetApplication(int) (*nr.Application, error)
+	GetApplications() ([]nr.Application, error)
+	GetApplicationHosts(int) ([]nr.ApplicationHost, error)
+	GetApplicationInstances(int) ([]nr.ApplicationInstance, error)
+	GetApplicationDeployments(int) ([]Deployment, error)
 }
 
 // APMClientImpl is a real implementation of an APMClient.
@@ -242,30 +485,108 @@ type APMClientImpl struct {
 
 // GetApplication fetches application information from New Relic (APM).
 func (a *APMClientImpl) GetApplication(appID int) (*nr.Application, error) {
-	c := nr.NewClient(a.APIKey)
+	c := newNRClient(a.APIKey)
 
 	return c.GetApplication(appID)
 }
 
+// GetApplications fetches all applications visible to the API key from New Relic (APM).
+func (a *APMClientImpl) GetApplications() ([]nr.Application, error) {
+	c := newNRClient(a.APIKey)
+
+	return c.GetApplications(&nr.ApplicationOptions{})
+}
+
+// GetApplicationHosts fetches the hosts of an application from New Relic (APM).
+func (a *APMClientImpl) GetApplicationHosts(appID int) ([]nr.ApplicationHost, error) {
+	c := newNRClient(a.APIKey)
+
+	return c.GetApplicationHosts(appID, &nr.ApplicationHostsOptions{})
+}
+
+// GetApplicationInstances fetches the instances of an application from New Relic (APM).
+func (a *APMClientImpl) GetApplicationInstances(appID int) ([]nr.ApplicationInstance, error) {
+	c := newNRClient(a.APIKey)
+
+	return c.GetApplicationInstances(appID, &nr.ApplicationInstancesOptions{})
+}
+
+// GetApplicationDeployments fetches the most recent deployments of an application from New Relic (APM).
+func (a *APMClientImpl) GetApplicationDeployments(appID int) ([]Deployment, error) {
+	r := newRESTClient(a.APIKey)
+
+	resp := struct {
+		Deployments []Deployment `json:"deployments"`
+	}{}
+
+	err := r.get(fmt.Sprintf("applications/%d/deployments.json", appID), url.Values{}, &resp)
+
+	return resp.Deployments, err
+}
+
+// partialApplicationsClient is implemented by the APM clients unable to supply every application field.
+type partialApplicationsClient interface {
+	UnsupportedApplicationPaths() []string
+}
+
 // APM represents the APM service part of New Relic.
 type APM struct {
-	APMClient APMClient
+	APMClient    APMClient
+	Applications *Applications
+	Workers      *Workers
 }
 
-// NewAPM creates and returns a new APM object with a configured APMClient.
-func NewAPM(apiKey string) Service {
+// NewAPM creates and returns a new APM object with the given APMClient, a shared applications lookup and the workers
+// of the collection, if any.
+func NewAPM(client APMClient, apps *Applications, workers *Workers) Service {
 	return &APM{
-		APMClient: &APMClientImpl{
-			APIKey: apiKey,
-		},
+		APMClient:    client,
+		Applications: apps,
+		Workers:      workers,
 	}
 }
 
+func (a *APM) applications() *Applications {
+	if a.Applications == nil {
+		a.Applications = NewApplications(a.APMClient)
+	}
+
+	return a.Applications
+}
+
 // GetMetricTypes returns the available APM metric types.
-func (a *APM) GetMetricTypes(_ plugin.Config) ([]plugin.Metric, error) {
+// When an API key is configured, the metric types are also returned for every application visible to it.
+func (a *APM) GetMetricTypes(cfg plugin.Config) ([]plugin.Metric, error) {
 	ns := plugin.NewNamespace("inteleon", "newrelic", "apm")
 
-	return metricTypes(ns, APMMetrics)
+	metrics, err := metricTypes(ns, APMMetrics)
+	if err != nil {
+		return metrics, err
+	}
+
+	if _, err := cfg.GetString("api_key"); err != nil {
+		// No API key, no applications to discover.
+		return metrics, nil
+	}
+
+	apps, err := a.applications().List()
+	if err != nil {
+		// Applications not discoverable, the generic metric types still work.
+		return metrics, nil
+	}
+
+	for _, app := range apps {
+		appMetrics, err := metricTypes(ns, withNamespaceValue(APMMetrics, "app_id", strconv.Itoa(app.ID)))
+		if err != nil {
+			return metrics, err
+		}
+
+		for i := range appMetrics {
+			metrics = append(metrics, appMetrics[i])
+		}
+	}
+
+	return metrics, nil
 }
 
 // CollectMetrics fetches the requested APM metrics and returns them.
@@ -276,23 +597,38 @@ func (a *APM) CollectMetrics(metrics []plugin.Metric) ([]plugin.Metric, error) {
 		return collectedMetrics, fmt.Errorf("List of metrics is empty")
 	}
 
-	apps := []plugin.Metric{}
+	metricsByType := map[string][]plugin.Metric{}
 	for i, m := range metrics {
 		if m.Namespace.Element(2).Value != "apm" {
 			continue
 		}
 
-		apps = append(apps, metrics[i])
+		metricType := m.Tags["Type"]
+		metricsByType[metricType] = append(metricsByType[metricType], metrics[i])
+	}
+
+	collectors := []struct {
+		metricType string
+		collect    func([]plugin.Metric) ([]plugin.Metric, error)
+	}{
+		{"application", a.collectApplications},
+		{"host", a.collectHosts},
+		{"instance", a.collectInstances},
+		{"deployment", a.collectDeployments},
 	}
 
-	if len(apps) > 0 {
-		appsMetrics, err := a.collectApplications(apps)
+	for _, c := range collectors {
+		if len(metricsByType[c.metricType]) == 0 {
+			continue
+		}
+
+		typeMetrics, err := c.collect(metricsByType[c.metricType])
 		if err != nil {
 			return collectedMetrics, err
 		}
 
-		for i := range appsMetrics {
-			collectedMetrics = append(collectedMetrics, appsMetrics[i])
+		for i := range typeMetrics {
+			collectedMetrics = append(collectedMetrics, typeMetrics[i])
 		}
 	}
 
@@ -303,33 +639,352 @@ func (a *APM) collectApplications(metrics []plugin.Metric) ([]plugin.Metric, err
 	appsMetrics := []plugin.Metric{}
 
 	apps := map[int]*nr.Application{}
+
+	// Expand wildcard app ids into one metric per application.
+	var allApps []nr.Application
+	requestedMetrics := []plugin.Metric{}
 	for i, m := range metrics {
-		appID := m.Namespace.Element(4)
+		if m.Namespace.Element(4).Value != "*" {
+			requestedMetrics = append(requestedMetrics, metrics[i])
 
-		appIDInt, err := strconv.Atoi(appID.Value)
-		if err != nil {
-			return appsMetrics, err
+			continue
 		}
 
-		if _, ok := apps[appIDInt]; !ok {
-			// Application info missing, fetching...
-			app, err := a.APMClient.GetApplication(appIDInt)
+		if allApps == nil {
+			// Application list missing, fetching it fresh, since its values are reported. Refreshing it keeps the
+			// cached list used to resolve names current as well.
+			fetchedApps, err := a.applications().Refresh()
 			if err != nil {
 				return appsMetrics, err
 			}
 
-			apps[appIDInt] = app
+			allApps = fetchedApps
+			for j := range allApps {
+				apps[allApps[j].ID] = &allApps[j]
+			}
+		}
+
+		for _, app := range allApps {
+			requestedMetrics = append(requestedMetrics, withElementValue(metrics[i], 4, strconv.Itoa(app.ID)))
 		}
+	}
+
+	requestedAppIDs := []int{}
+	missingAppIDs := []int{}
+	for _, m := range requestedMetrics {
+		appID := m.Namespace.Element(4)
+
+		appIDInt, err := a.applications().ResolveID(appID.Value)
+		if err != nil {
+			return appsMetrics, err
+		}
+
+		if _, ok := apps[appIDInt]; !ok && !containsInt(missingAppIDs, appIDInt) {
+			missingAppIDs = append(missingAppIDs, appIDInt)
+		}
+
+		requestedAppIDs = append(requestedAppIDs, appIDInt)
+	}
+
+	// Application info missing, fetching the applications concurrently...
+	fetchedApps := make([]*nr.Application, len(missingAppIDs))
+	errs := serviceWorkers(a.Workers, metrics).parallel(len(missingAppIDs), func(j int) error {
+		var err error
+		fetchedApps[j], err = a.APMClient.GetApplication(missingAppIDs[j])
+
+		return err
+	})
+
+	if err := firstError(errs); err != nil {
+		return appsMetrics, err
+	}
+
+	for j, appIDInt := range missingAppIDs {
+		apps[appIDInt] = fetchedApps[j]
+	}
+
+	for i := range requestedMetrics {
+		appIDInt := requestedAppIDs[i]
 
 		// Convert the app data to a struct so it's more easily traversable and more universal before passing it to the populateMetric function.
-		appMetric, err := populateMetric(metrics[i], structs.Map(apps[appIDInt]))
+		appData := structs.Map(apps[appIDInt])
+		if client, ok := a.APMClient.(partialApplicationsClient); ok {
+			// Fields the client doesn't supply are left out rather than reported as zeros.
+			for _, path := range client.UnsupportedApplicationPaths() {
+				mapDelete(appData, strings.Split(path, "/"))
+			}
+		}
+
+		appMetric, err := populateMetric(requestedMetrics[i], appData, applicationTags(apps[appIDInt]))
 		if err != nil {
 			// Metric not found, skip reporting it and continue execution.
 			continue
 		}
 
-		appsMetrics = append(appsMetrics, appMetric)
+		appsMetrics = append(appsMetrics, withSourceTimestamp(appMetric, apps[appIDInt].LastReportedAt))
 	}
 
 	return appsMetrics, nil
 }
+
+func (a *APM) collectDeployments(metrics []plugin.Metric) ([]plugin.Metric, error) {
+	deploymentsMetrics := []plugin.Metric{}
+
+	metricsAppIDs, allAppIDs, err := a.resolveMetricsAppIDs(metrics)
+	if err != nil {
+		return deploymentsMetrics, err
+	}
+
+	// Deployments missing, fetching the deployments of the applications concurrently...
+	fetchedDeployments := make([][]Deployment, len(allAppIDs))
+	errs := serviceWorkers(a.Workers, metrics).parallel(len(allAppIDs), func(j int) error {
+		var err error
+		fetchedDeployments[j], err = a.APMClient.GetApplicationDeployments(allAppIDs[j])
+
+		return err
+	})
+
+	if err := firstError(errs); err != nil {
+		return deploymentsMetrics, err
+	}
+
+	latestDeployments := map[int]*Deployment{}
+	for j, appIDInt := range allAppIDs {
+		deployments := fetchedDeployments[j]
+
+		latestDeployments[appIDInt] = nil
+		for k := range deployments {
+			if latestDeployments[appIDInt] == nil || deployments[k].Timestamp.After(latestDeployments[appIDInt].Timestamp) {
+				latestDeployments[appIDInt] = &deployments[k]
+			}
+		}
+	}
+
+	for i, m := range metrics {
+		appID := m.Namespace.Element(4).Value
+
+		for _, appIDInt := range metricsAppIDs[i] {
+			deployment := latestDeployments[appIDInt]
+			if deployment == nil {
+				// The application has never been deployed, nothing to report.
+				continue
+			}
+
+			deploymentMetric := metrics[i]
+			if appID == "*" {
+				deploymentMetric = withElementValue(deploymentMetric, 4, strconv.Itoa(appIDInt))
+			}
+
+			tags := map[string]string{
+				"app_id": strconv.Itoa(appIDInt),
+			}
+
+			if app, err := a.applications().Find(strconv.Itoa(appIDInt)); err == nil {
+				tags = applicationTags(app)
+			}
+
+			tags["revision"] = deployment.Revision
+			tags["timestamp"] = deployment.Timestamp.UTC().Format(time.RFC3339)
+			tags["user"] = deployment.User
+			tags["description"] = deployment.Description
+
+			populatedMetric, err := populateMetric(
+				deploymentMetric,
+				map[string]interface{}{
+					"SecondsSince": int(time.Since(deployment.Timestamp).Seconds()),
+				},
+				tags,
+			)
+			if err != nil {
+				// Metric not found, skip reporting it and continue execution.
+				continue
+			}
+
+			deploymentsMetrics = append(deploymentsMetrics, populatedMetric)
+		}
+	}
+
+	return deploymentsMetrics, nil
+}
+
+// appEntity is a single entity, like a host or an instance, belonging to an application.
+type appEntity struct {
+	ID   string
+	Name string
+	Data map[string]interface{}
+	Tags map[string]string
+}
+
+func (a *APM) collectHosts(metrics []plugin.Metric) ([]plugin.Metric, error) {
+	return a.collectAppEntities(metrics, func(appID int) ([]appEntity, error) {
+		hosts, err := a.APMClient.GetApplicationHosts(appID)
+		if err != nil {
+			return nil, err
+		}
+
+		entities := []appEntity{}
+		for i := range hosts {
+			entities = append(entities, appEntity{
+				ID:   strconv.Itoa(hosts[i].ID),
+				Name: hosts[i].Host,
+				Data: structs.Map(hosts[i]),
+				Tags: map[string]string{
+					"app_id":        strconv.Itoa(appID),
+					"app_name":      hosts[i].ApplicationName,
+					"language":      hosts[i].Language,
+					"health_status": hosts[i].HealthStatus,
+					"host_id":       strconv.Itoa(hosts[i].ID),
+					"hostname":      hosts[i].Host,
+				},
+			})
+		}
+
+		return entities, nil
+	})
+}
+
+func (a *APM) collectInstances(metrics []plugin.Metric) ([]plugin.Metric, error) {
+	return a.collectAppEntities(metrics, func(appID int) ([]appEntity, error) {
+		instances, err := a.APMClient.GetApplicationInstances(appID)
+		if err != nil {
+			return nil, err
+		}
+
+		entities := []appEntity{}
+		for i := range instances {
+			entities = append(entities, appEntity{
+				ID:   strconv.Itoa(instances[i].ID),
+				Data: structs.Map(instances[i]),
+				Tags: map[string]string{
+					"app_id":        strconv.Itoa(appID),
+					"app_name":      instances[i].ApplicationName,
+					"language":      instances[i].Language,
+					"health_status": instances[i].HealthStatus,
+					"instance_id":   strconv.Itoa(instances[i].ID),
+					"hostname":      instances[i].Host,
+				},
+			})
+		}
+
+		return entities, nil
+	})
+}
+
+// collectAppEntities populates metrics for entities belonging to an application. The app id is the 5th namespace
+// element and the entity id the 7th, both accept a wildcard. The entity can also be requested by name.
+func (a *APM) collectAppEntities(metrics []plugin.Metric, fetch func(int) ([]appEntity, error)) ([]plugin.Metric, error) {
+	entitiesMetrics := []plugin.Metric{}
+
+	metricsAppIDs, allAppIDs, err := a.resolveMetricsAppIDs(metrics)
+	if err != nil {
+		return entitiesMetrics, err
+	}
+
+	// Entities missing, fetching the entities of the applications concurrently...
+	fetchedEntities := make([][]appEntity, len(allAppIDs))
+	errs := serviceWorkers(a.Workers, metrics).parallel(len(allAppIDs), func(j int) error {
+		var err error
+		fetchedEntities[j], err = fetch(allAppIDs[j])
+
+		return err
+	})
+
+	if err := firstError(errs); err != nil {
+		return entitiesMetrics, err
+	}
+
+	entities := map[int][]appEntity{}
+	for j, appIDInt := range allAppIDs {
+		entities[appIDInt] = fetchedEntities[j]
+	}
+
+	for i, m := range metrics {
+		appID := m.Namespace.Element(4).Value
+		entityID := m.Namespace.Element(6).Value
+
+		for _, appIDInt := range metricsAppIDs[i] {
+			for _, e := range entities[appIDInt] {
+				if entityID != "*" && entityID != e.ID && entityID != e.Name {
+					continue
+				}
+
+				entityMetric := metrics[i]
+				if appID == "*" {
+					entityMetric = withElementValue(entityMetric, 4, strconv.Itoa(appIDInt))
+				}
+
+				if entityID == "*" {
+					entityMetric = withElementValue(entityMetric, 6, e.ID)
+				}
+
+				populatedMetric, err := populateMetric(entityMetric, e.Data, e.Tags)
+				if err != nil {
+					// Metric not found, skip reporting it and continue execution.
+					continue
+				}
+
+				entitiesMetrics = append(entitiesMetrics, populatedMetric)
+			}
+		}
+	}
+
+	return entitiesMetrics, nil
+}
+
+// resolveMetricsAppIDs returns the application ids matching the app id namespace element of every metric, and all
+// distinct application ids in the order they're requested.
+func (a *APM) resolveMetricsAppIDs(metrics []plugin.Metric) ([][]int, []int, error) {
+	metricsAppIDs := [][]int{}
+	allAppIDs := []int{}
+	for _, m := range metrics {
+		appIDs, err := a.resolveAppIDs(m.Namespace.Element(4).Value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, appIDInt := range appIDs {
+			if !containsInt(allAppIDs, appIDInt) {
+				allAppIDs = append(allAppIDs, appIDInt)
+			}
+		}
+
+		metricsAppIDs = append(metricsAppIDs, appIDs)
+	}
+
+	return metricsAppIDs, allAppIDs, nil
+}
+
+// containsInt returns whether the list contains the value.
+func containsInt(list []int, value int) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveAppIDs returns the application ids matching an app id namespace element value.
+func (a *APM) resolveAppIDs(appID string) ([]int, error) {
+	if appID != "*" {
+		appIDInt, err := a.applications().ResolveID(appID)
+		if err != nil {
+			return nil, err
+		}
+
+		return []int{appIDInt}, nil
+	}
+
+	apps, err := a.applications().List()
+	if err != nil {
+		return nil, err
+	}
+
+	appIDs := []int{}
+	for _, app := range apps {
+		appIDs = append(appIDs, app.ID)
+	}
+
+	return appIDs, nil
+}
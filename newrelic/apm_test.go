@@ -6,24 +6,145 @@ import (
 	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
 	nr "github.com/yfronto/newrelic"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type apmClientTestImpl struct {
+	mu               sync.Mutex
 	appIDs           []int
 	metricDataAppIDs []int
 	metricDataNames  map[int][]string
+	appsCalls        int
+	hostsAppIDs      []int
+	instancesAppIDs  []int
+	deploymentAppIDs []int
 }
 
 func (a *apmClientTestImpl) GetApplication(appID int) (*nr.Application, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	a.appIDs = append(a.appIDs, appID)
 
 	return &nr.Application{
+		ID:       appID,
+		Name:     "hax",
+		Language: "go",
 		ApplicationSummary: nr.ApplicationSummary{
 			ResponseTime: 13.37,
 		},
-		HealthStatus: "awesome",
-		Reporting:    true,
+		HealthStatus:   "awesome",
+		Reporting:      true,
+		LastReportedAt: time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC),
+	}, nil
+}
+
+func (a *apmClientTestImpl) GetApplications() ([]nr.Application, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.appsCalls++
+
+	return []nr.Application{
+		{
+			ID:   1337,
+			Name: "hax",
+			ApplicationSummary: nr.ApplicationSummary{
+				ResponseTime: 13.37,
+			},
+			HealthStatus: "awesome",
+			Reporting:    true,
+		},
+		{
+			ID:   1234,
+			Name: "leet",
+			ApplicationSummary: nr.ApplicationSummary{
+				ResponseTime: 12.34,
+			},
+			HealthStatus: "meh",
+			Reporting:    false,
+		},
+	}, nil
+}
+
+func (a *apmClientTestImpl) GetApplicationHosts(appID int) ([]nr.ApplicationHost, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.hostsAppIDs = append(a.hostsAppIDs, appID)
+
+	return []nr.ApplicationHost{
+		{
+			ID:              1,
+			Host:            "web-1",
+			ApplicationName: "hax",
+			HealthStatus:    "green",
+			ApplicationSummary: nr.ApplicationHostSummary{
+				ResponseTime: 10.5,
+			},
+		},
+		{
+			ID:              2,
+			Host:            "web-2",
+			ApplicationName: "hax",
+			HealthStatus:    "red",
+			ApplicationSummary: nr.ApplicationHostSummary{
+				ResponseTime: 99.5,
+			},
+		},
+	}, nil
+}
+
+func (a *apmClientTestImpl) GetApplicationInstances(appID int) ([]nr.ApplicationInstance, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.instancesAppIDs = append(a.instancesAppIDs, appID)
+
+	return []nr.ApplicationInstance{
+		{
+			ID:              11,
+			Host:            "web-1",
+			ApplicationName: "hax",
+			ApplicationSummary: nr.ApplicationInstanceSummary{
+				Throughput: 120,
+			},
+		},
+		{
+			ID:              12,
+			Host:            "web-1",
+			ApplicationName: "hax",
+			ApplicationSummary: nr.ApplicationInstanceSummary{
+				Throughput: 80,
+			},
+		},
+	}, nil
+}
+
+func (a *apmClientTestImpl) GetApplicationDeployments(appID int) ([]newrelic.Deployment, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.deploymentAppIDs = append(a.deploymentAppIDs, appID)
+
+	if appID != 1337 {
+		return []newrelic.Deployment{}, nil
+	}
+
+	return []newrelic.Deployment{
+		{
+			Revision:  "abc123",
+			User:      "deployer",
+			Timestamp: time.Now().Add(-2 * time.Hour),
+		},
+		{
+			Revision:    "def456",
+			User:        "deployer",
+			Description: "Hotfix",
+			Timestamp:   time.Now().Add(-1 * time.Hour),
+		},
 	}, nil
 }
 
@@ -52,6 +173,70 @@ func TestGetAppMetricTypesSuccess(t *testing.T) {
 	}
 }
 
+func TestGetAppMetricTypesDiscoverySuccess(t *testing.T) {
+	apmClient := &apmClientTestImpl{}
+
+	a := &newrelic.APM{
+		APMClient: apmClient,
+	}
+
+	metrics, err := a.GetMetricTypes(plugin.Config{"api_key": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if apmClient.appsCalls != 1 {
+		t.Fatal("expected", 1, "got", apmClient.appsCalls)
+	}
+
+	expectedLen := len(newrelic.APMMetrics) * 3
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	for i, appID := range []string{"*", "1337", "1234"} {
+		for j, m := range metrics[i*len(newrelic.APMMetrics) : (i+1)*len(newrelic.APMMetrics)] {
+			expectedNS := fmt.Sprintf(
+				"inteleon/newrelic/apm/application/%s/%s",
+				appID,
+				strings.Join(newrelic.APMMetrics[j].Namespace.Strings()[2:], "/"),
+			)
+			ns := strings.Join(m.Namespace.Strings(), "/")
+
+			if ns != expectedNS {
+				t.Fatal("expected", expectedNS, "got", ns)
+			}
+		}
+	}
+
+	if newrelic.APMMetrics[0].Namespace.Element(1).Value != "*" {
+		t.Fatal("expected", "*", "got", newrelic.APMMetrics[0].Namespace.Element(1).Value)
+	}
+}
+
+type failingAPMClientTestImpl struct {
+	apmClientTestImpl
+}
+
+func (a *failingAPMClientTestImpl) GetApplications() ([]nr.Application, error) {
+	return nil, fmt.Errorf("New Relic API request failed with status 500: oops")
+}
+
+func TestGetAppMetricTypesDiscoveryFailure(t *testing.T) {
+	a := &newrelic.APM{
+		APMClient: &failingAPMClientTestImpl{},
+	}
+
+	metrics, err := a.GetMetricTypes(plugin.Config{"api_key": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(metrics) != len(newrelic.APMMetrics) {
+		t.Fatal("expected", len(newrelic.APMMetrics), "got", len(metrics))
+	}
+}
+
 func TestCollectAppMetricsAppIDSuccess(t *testing.T) {
 	apmClient := &apmClientTestImpl{}
 
@@ -59,9 +244,11 @@ func TestCollectAppMetricsAppIDSuccess(t *testing.T) {
 		APMClient: apmClient,
 	}
 
+	// A single worker fetches the applications in the order they're requested.
 	metrics := []plugin.Metric{
 		{
 			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "1337", "show", "health", "status"),
+			Config:    plugin.Config{"workers": int64(1)},
 			Tags: map[string]string{
 				"Type": "application",
 				"Path": "HealthStatus",
@@ -118,6 +305,22 @@ func TestCollectAppMetricsAppIDSuccess(t *testing.T) {
 		t.Fatal("expected", true, "got", false)
 	}
 
+	expectedTags := map[string]string{
+		"app_id":        "1234",
+		"app_name":      "hax",
+		"language":      "go",
+		"health_status": "awesome",
+	}
+	for k, v := range expectedTags {
+		if ret[1].Tags[k] != v {
+			t.Fatal("expected", v, "got", ret[1].Tags[k])
+		}
+	}
+
+	if _, ok := ret[1].Tags["Path"]; ok {
+		t.Fatal("expected", "no Path tag", "got", ret[1].Tags["Path"])
+	}
+
 	if len(apmClient.appIDs) != 2 {
 		t.Fatal("expected", 2, "got", len(apmClient.appIDs))
 	}
@@ -129,3 +332,374 @@ func TestCollectAppMetricsAppIDSuccess(t *testing.T) {
 		}
 	}
 }
+
+func TestCollectAppMetricsTimestampSuccess(t *testing.T) {
+	a := &newrelic.APM{
+		APMClient: &apmClientTestImpl{},
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "1337", "show", "health", "status"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Path": "HealthStatus",
+				"Unit": "string",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "1337", "show", "reporting"),
+			Config:    plugin.Config{"timestamp_source": "collection"},
+			Tags: map[string]string{
+				"Type": "application",
+				"Path": "Reporting",
+				"Unit": "bool",
+			},
+		},
+	}
+
+	start := time.Now()
+
+	ret, err := a.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 2 {
+		t.Fatal("expected", 2, "got", len(ret))
+	}
+
+	lastReportedAt := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	if !ret[0].Timestamp.Equal(lastReportedAt) {
+		t.Fatal("expected", lastReportedAt, "got", ret[0].Timestamp)
+	}
+
+	if ret[1].Timestamp.Before(start) {
+		t.Fatal("expected", "collection time", "got", ret[1].Timestamp)
+	}
+}
+
+func TestCollectAppMetricsWildcardAppIDSuccess(t *testing.T) {
+	apmClient := &apmClientTestImpl{}
+
+	a := &newrelic.APM{
+		APMClient: apmClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "*", "show", "summary", "application", "response_time"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Path": "ApplicationSummary/ResponseTime",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "*", "show", "health", "status"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Path": "HealthStatus",
+				"Unit": "string",
+			},
+		},
+	}
+
+	ret, err := a.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 4 {
+		t.Fatal("expected", 4, "got", len(ret))
+	}
+
+	expected := []struct {
+		appID string
+		data  interface{}
+	}{
+		{"1337", 13.37},
+		{"1234", 12.34},
+		{"1337", "awesome"},
+		{"1234", "meh"},
+	}
+	for i, e := range expected {
+		if ret[i].Namespace.Element(4).Value != e.appID {
+			t.Fatal("expected", e.appID, "got", ret[i].Namespace.Element(4).Value)
+		}
+
+		if ret[i].Data != e.data {
+			t.Fatal("expected", e.data, "got", ret[i].Data)
+		}
+	}
+
+	if metrics[0].Namespace.Element(4).Value != "*" {
+		t.Fatal("expected", "*", "got", metrics[0].Namespace.Element(4).Value)
+	}
+
+	if apmClient.appsCalls != 1 {
+		t.Fatal("expected", 1, "got", apmClient.appsCalls)
+	}
+
+	if len(apmClient.appIDs) != 0 {
+		t.Fatal("expected", 0, "got", len(apmClient.appIDs))
+	}
+
+	// The reported values are current, collecting again fetches the application list again.
+	if _, err := a.CollectMetrics(metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	if apmClient.appsCalls != 2 {
+		t.Fatal("expected", 2, "got", apmClient.appsCalls)
+	}
+}
+
+func TestCollectAppMetricsAppNameSuccess(t *testing.T) {
+	apmClient := &apmClientTestImpl{}
+
+	a := &newrelic.APM{
+		APMClient: apmClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "leet", "show", "health", "status"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Path": "HealthStatus",
+				"Unit": "string",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "leet", "show", "reporting"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Path": "Reporting",
+				"Unit": "bool",
+			},
+		},
+	}
+
+	ret, err := a.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 2 {
+		t.Fatal("expected", 2, "got", len(ret))
+	}
+
+	if ret[0].Namespace.Element(4).Value != "leet" {
+		t.Fatal("expected", "leet", "got", ret[0].Namespace.Element(4).Value)
+	}
+
+	if apmClient.appsCalls != 1 {
+		t.Fatal("expected", 1, "got", apmClient.appsCalls)
+	}
+
+	if len(apmClient.appIDs) != 1 {
+		t.Fatal("expected", 1, "got", len(apmClient.appIDs))
+	}
+
+	if apmClient.appIDs[0] != 1234 {
+		t.Fatal("expected", 1234, "got", apmClient.appIDs[0])
+	}
+}
+
+func TestCollectAppMetricsAppNameNotFoundFailure(t *testing.T) {
+	a := &newrelic.APM{
+		APMClient: &apmClientTestImpl{},
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "h4x", "show", "health", "status"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Path": "HealthStatus",
+				"Unit": "string",
+			},
+		},
+	}
+
+	_, err := a.CollectMetrics(metrics)
+	if err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+
+	expectedErrStr := "Application not found: h4x"
+	if err.Error() != expectedErrStr {
+		t.Fatal("expected", expectedErrStr, "got", err.Error())
+	}
+}
+
+func TestCollectAppHostMetricsSuccess(t *testing.T) {
+	apmClient := &apmClientTestImpl{}
+
+	a := &newrelic.APM{
+		APMClient: apmClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "1337", "host", "*", "summary", "response_time"),
+			Tags: map[string]string{
+				"Type": "host",
+				"Path": "ApplicationSummary/ResponseTime",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "1337", "host", "web-2", "summary", "response_time"),
+			Tags: map[string]string{
+				"Type": "host",
+				"Path": "ApplicationSummary/ResponseTime",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := a.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 3 {
+		t.Fatal("expected", 3, "got", len(ret))
+	}
+
+	expected := []struct {
+		hostID   string
+		hostname string
+		data     float64
+	}{
+		{"1", "web-1", 10.5},
+		{"2", "web-2", 99.5},
+		{"web-2", "web-2", 99.5},
+	}
+	for i, e := range expected {
+		if ret[i].Namespace.Element(6).Value != e.hostID {
+			t.Fatal("expected", e.hostID, "got", ret[i].Namespace.Element(6).Value)
+		}
+
+		if ret[i].Tags["hostname"] != e.hostname {
+			t.Fatal("expected", e.hostname, "got", ret[i].Tags["hostname"])
+		}
+
+		if ret[i].Data.(float64) != e.data {
+			t.Fatal("expected", e.data, "got", ret[i].Data.(float64))
+		}
+	}
+
+	if len(apmClient.hostsAppIDs) != 1 {
+		t.Fatal("expected", 1, "got", len(apmClient.hostsAppIDs))
+	}
+
+	if apmClient.hostsAppIDs[0] != 1337 {
+		t.Fatal("expected", 1337, "got", apmClient.hostsAppIDs[0])
+	}
+}
+
+func TestCollectAppInstanceMetricsSuccess(t *testing.T) {
+	apmClient := &apmClientTestImpl{}
+
+	a := &newrelic.APM{
+		APMClient: apmClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "*", "instance", "12", "summary", "throughput"),
+			Tags: map[string]string{
+				"Type": "instance",
+				"Path": "ApplicationSummary/Throughput",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := a.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 2 {
+		t.Fatal("expected", 2, "got", len(ret))
+	}
+
+	for i, appID := range []string{"1337", "1234"} {
+		if ret[i].Namespace.Element(4).Value != appID {
+			t.Fatal("expected", appID, "got", ret[i].Namespace.Element(4).Value)
+		}
+
+		if ret[i].Data.(float64) != 80 {
+			t.Fatal("expected", 80, "got", ret[i].Data.(float64))
+		}
+
+		if ret[i].Tags["instance_id"] != "12" {
+			t.Fatal("expected", "12", "got", ret[i].Tags["instance_id"])
+		}
+
+		if ret[i].Tags["hostname"] != "web-1" {
+			t.Fatal("expected", "web-1", "got", ret[i].Tags["hostname"])
+		}
+	}
+
+	if len(apmClient.instancesAppIDs) != 2 {
+		t.Fatal("expected", 2, "got", len(apmClient.instancesAppIDs))
+	}
+}
+
+func TestCollectAppDeploymentMetricsSuccess(t *testing.T) {
+	apmClient := &apmClientTestImpl{}
+
+	a := &newrelic.APM{
+		APMClient: apmClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "*", "show", "deployment", "seconds_since"),
+			Tags: map[string]string{
+				"Type": "deployment",
+				"Path": "SecondsSince",
+				"Unit": "int",
+			},
+		},
+	}
+
+	ret, err := a.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 1 {
+		t.Fatal("expected", 1, "got", len(ret))
+	}
+
+	if ret[0].Namespace.Element(4).Value != "1337" {
+		t.Fatal("expected", "1337", "got", ret[0].Namespace.Element(4).Value)
+	}
+
+	secondsSince := ret[0].Data.(int)
+	if secondsSince < 3600 || secondsSince > 3660 {
+		t.Fatal("expected", 3600, "got", secondsSince)
+	}
+
+	expectedTags := map[string]string{
+		"app_name":    "hax",
+		"revision":    "def456",
+		"user":        "deployer",
+		"description": "Hotfix",
+	}
+	for k, v := range expectedTags {
+		if ret[0].Tags[k] != v {
+			t.Fatal("expected", v, "got", ret[0].Tags[k])
+		}
+	}
+
+	if len(apmClient.deploymentAppIDs) != 2 {
+		t.Fatal("expected", 2, "got", len(apmClient.deploymentAppIDs))
+	}
+}
@@ -0,0 +1,113 @@
+package newrelic
+
+import (
+	"fmt"
+	nr "github.com/yfronto/newrelic"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ApplicationsCacheTTL is how long a fetched applications list is reused before it's fetched again.
+const ApplicationsCacheTTL = 10 * time.Minute
+
+// ApplicationsClient is the interface every client able to list applications needs to implement.
+type ApplicationsClient interface {
+	GetApplications() ([]nr.Application, error)
+}
+
+// Applications is a cached lookup of the applications visible to an API key.
+type Applications struct {
+	Client ApplicationsClient
+	TTL    time.Duration
+
+	mu        sync.Mutex
+	apps      []nr.Application
+	fetchedAt time.Time
+}
+
+// NewApplications creates and returns a new Applications lookup using the given client.
+func NewApplications(client ApplicationsClient) *Applications {
+	return &Applications{
+		Client: client,
+		TTL:    ApplicationsCacheTTL,
+	}
+}
+
+// List returns all applications, fetching them if the cached list is missing or expired.
+func (a *Applications) List() ([]nr.Application, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.apps != nil && time.Since(a.fetchedAt) < a.TTL {
+		return a.apps, nil
+	}
+
+	return a.fetch()
+}
+
+// Refresh fetches all applications regardless of the cached list, caches them and returns them. It's used when the
+// application values have to be current rather than just the ids and names.
+func (a *Applications) Refresh() ([]nr.Application, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.fetch()
+}
+
+func (a *Applications) fetch() ([]nr.Application, error) {
+	apps, err := a.Client.GetApplications()
+	if err != nil {
+		return nil, err
+	}
+
+	a.apps = apps
+	a.fetchedAt = time.Now()
+
+	return a.apps, nil
+}
+
+// Find returns the application matching the given application id or name.
+func (a *Applications) Find(idOrName string) (*nr.Application, error) {
+	apps, err := a.List()
+	if err != nil {
+		return nil, err
+	}
+
+	id, idErr := strconv.Atoi(idOrName)
+	for i := range apps {
+		if (idErr == nil && apps[i].ID == id) || apps[i].Name == idOrName {
+			return &apps[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("Application not found: %s", idOrName)
+}
+
+// ResolveID returns the application id for the given application id or name. Numeric ids are returned as is.
+func (a *Applications) ResolveID(idOrName string) (int, error) {
+	if id, err := strconv.Atoi(idOrName); err == nil {
+		return id, nil
+	}
+
+	if a == nil {
+		return 0, fmt.Errorf("Unable to resolve application name: %s", idOrName)
+	}
+
+	app, err := a.Find(idOrName)
+	if err != nil {
+		return 0, err
+	}
+
+	return app.ID, nil
+}
+
+// applicationTags returns the tags describing an application.
+func applicationTags(app *nr.Application) map[string]string {
+	return map[string]string{
+		"app_id":        strconv.Itoa(app.ID),
+		"app_name":      app.Name,
+		"language":      app.Language,
+		"health_status": app.HealthStatus,
+	}
+}
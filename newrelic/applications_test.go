@@ -0,0 +1,104 @@
+package newrelic_test
+
+import (
+	"github.com/inteleon/snap-plugin-collector-newrelic/newrelic"
+	"testing"
+	"time"
+)
+
+func TestApplicationsFindCachedSuccess(t *testing.T) {
+	apmClient := &apmClientTestImpl{}
+
+	apps := newrelic.NewApplications(apmClient)
+
+	for _, idOrName := range []string{"hax", "1234", "leet"} {
+		if _, err := apps.Find(idOrName); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if apmClient.appsCalls != 1 {
+		t.Fatal("expected", 1, "got", apmClient.appsCalls)
+	}
+
+	app, err := apps.Find("leet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if app.ID != 1234 {
+		t.Fatal("expected", 1234, "got", app.ID)
+	}
+}
+
+func TestApplicationsListExpiredSuccess(t *testing.T) {
+	apmClient := &apmClientTestImpl{}
+
+	apps := newrelic.NewApplications(apmClient)
+	apps.TTL = time.Duration(0)
+
+	for i := 0; i < 2; i++ {
+		if _, err := apps.List(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if apmClient.appsCalls != 2 {
+		t.Fatal("expected", 2, "got", apmClient.appsCalls)
+	}
+}
+
+func TestApplicationsRefreshSuccess(t *testing.T) {
+	apmClient := &apmClientTestImpl{}
+
+	apps := newrelic.NewApplications(apmClient)
+
+	if _, err := apps.List(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := apps.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	if apmClient.appsCalls != 2 {
+		t.Fatal("expected", 2, "got", apmClient.appsCalls)
+	}
+
+	// The refreshed list is cached.
+	if _, err := apps.List(); err != nil {
+		t.Fatal(err)
+	}
+
+	if apmClient.appsCalls != 2 {
+		t.Fatal("expected", 2, "got", apmClient.appsCalls)
+	}
+}
+
+func TestApplicationsResolveIDSuccess(t *testing.T) {
+	apmClient := &apmClientTestImpl{}
+
+	apps := newrelic.NewApplications(apmClient)
+
+	id, err := apps.ResolveID("31337")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 31337 {
+		t.Fatal("expected", 31337, "got", id)
+	}
+
+	if apmClient.appsCalls != 0 {
+		t.Fatal("expected", 0, "got", apmClient.appsCalls)
+	}
+
+	id, err = apps.ResolveID("hax")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 1337 {
+		t.Fatal("expected", 1337, "got", id)
+	}
+}
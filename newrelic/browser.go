@@ -0,0 +1,430 @@
+package newrelic
+
+import (
+	"fmt"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	nr "github.com/yfronto/newrelic"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BrowserMetrics is a list containing the available browser metrics and their properties.
+var BrowserMetrics = []Metric{
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Browser application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("page_load_time"),
+		},
+		Type: "summary",
+		Path: "PageLoadTime",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Browser application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("page_views_per_minute"),
+		},
+		Type: "summary",
+		Path: "PageViewsPerMinute",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Browser application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("page_views"),
+		},
+		Type: "summary",
+		Path: "PageViews",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Browser application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("apdex_score"),
+		},
+		Type: "summary",
+		Path: "ApdexScore",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Browser application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("page_view"),
+			plugin.NamespaceElement{
+				Name:        "minutes",
+				Description: "Number of minutes to construct a relative timeframe from (now - minutes).",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "metric_name",
+				Description: "Metric name",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "value_name",
+				Description: "Value name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("value"),
+		},
+		Type: "page_view",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Browser application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("ajax"),
+			plugin.NamespaceElement{
+				Name:        "minutes",
+				Description: "Number of minutes to construct a relative timeframe from (now - minutes).",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "metric_name",
+				Description: "Metric name",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "value_name",
+				Description: "Value name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("value"),
+		},
+		Type: "ajax",
+		Unit: "float",
+	},
+}
+
+// browserSummaryNames are the metric names the browser summary is built from.
+var browserSummaryNames = []string{"EndUser", "EndUser/Apdex"}
+
+// browserSummaryValues maps every browser summary field to the metric name and value name it's read from.
+var browserSummaryValues = map[string][2]string{
+	"PageLoadTime":       {"EndUser", "average_response_time"},
+	"PageViewsPerMinute": {"EndUser", "calls_per_minute"},
+	"PageViews":          {"EndUser", "call_count"},
+	"ApdexScore":         {"EndUser/Apdex", "score"},
+}
+
+// browserMetricNamePrefixes maps every browser metric data type to the prefix its metric names start with.
+var browserMetricNamePrefixes = map[string]string{
+	"page_view": "BrowserPageView",
+	"ajax":      "AjaxCall",
+}
+
+// BrowserClient is the interface every browser client needs to implement.
+type BrowserClient interface {
+	GetBrowserApplications() ([]nr.BrowserApplication, error)
+	GetApplicationMetricData(int, []string, *nr.MetricDataOptions) (*nr.MetricDataResponse, error)
+}
+
+// BrowserClientImpl is a real implementation of a BrowserClient.
+type BrowserClientImpl struct {
+	APIKey string
+}
+
+// GetBrowserApplications fetches all browser applications from New Relic.
+func (b *BrowserClientImpl) GetBrowserApplications() ([]nr.BrowserApplication, error) {
+	c := newNRClient(b.APIKey)
+
+	return c.GetBrowserApplications(&nr.BrowserApplicationsOptions{})
+}
+
+// GetApplicationMetricData fetches browser application specific metric data.
+func (b *BrowserClientImpl) GetApplicationMetricData(appID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	c := newNRClient(b.APIKey)
+
+	return c.GetApplicationMetricData(appID, names, options)
+}
+
+// Browser represents the browser service part of New Relic.
+type Browser struct {
+	BrowserClient BrowserClient
+	Clock         func() time.Time
+	Workers       *Workers
+}
+
+// NewBrowser creates and returns a new Browser object with a configured BrowserClient and the workers of the
+// collection, if any.
+func NewBrowser(apiKey string, workers *Workers) Service {
+	return &Browser{
+		BrowserClient: &BrowserClientImpl{
+			APIKey: apiKey,
+		},
+		Workers: workers,
+	}
+}
+
+// GetMetricTypes returns the available browser metric types.
+// When an API key is configured, the metric types are also returned for every browser application visible to it.
+func (b *Browser) GetMetricTypes(cfg plugin.Config) ([]plugin.Metric, error) {
+	ns := plugin.NewNamespace("inteleon", "newrelic", "browser")
+
+	metrics, err := metricTypes(ns, BrowserMetrics)
+	if err != nil {
+		return metrics, err
+	}
+
+	if _, err := cfg.GetString("api_key"); err != nil {
+		// No API key, no browser applications to discover.
+		return metrics, nil
+	}
+
+	apps, err := b.BrowserClient.GetBrowserApplications()
+	if err != nil {
+		// Browser applications not discoverable, the generic metric types still work.
+		return metrics, nil
+	}
+
+	for _, app := range apps {
+		appMetrics, err := metricTypes(ns, withNamespaceValue(BrowserMetrics, "app_id", strconv.Itoa(app.ID)))
+		if err != nil {
+			return metrics, err
+		}
+
+		for i := range appMetrics {
+			metrics = append(metrics, appMetrics[i])
+		}
+	}
+
+	return metrics, nil
+}
+
+// CollectMetrics fetches the requested browser metrics and returns them.
+func (b *Browser) CollectMetrics(metrics []plugin.Metric) ([]plugin.Metric, error) {
+	collectedMetrics := []plugin.Metric{}
+
+	if len(metrics) == 0 {
+		return collectedMetrics, fmt.Errorf("List of metrics is empty")
+	}
+
+	var apps []nr.BrowserApplication
+	var fetches []*browserFetch
+	var targets []browserTarget
+	fetchesByKey := map[string]*browserFetch{}
+	for i, m := range metrics {
+		if m.Namespace.Element(2).Value != "browser" {
+			continue
+		}
+
+		if prefix, ok := browserMetricNamePrefixes[m.Tags["Type"]]; ok && !strings.HasPrefix(m.Namespace.Element(7).Value, prefix) {
+			// Metric name of another type, New Relic doesn't report it for this type, skip reporting it and continue
+			// execution.
+			continue
+		}
+
+		if apps == nil {
+			// Browser applications missing, fetching...
+			fetchedApps, err := b.BrowserClient.GetBrowserApplications()
+			if err != nil {
+				return collectedMetrics, err
+			}
+
+			apps = fetchedApps
+		}
+
+		appID := m.Namespace.Element(4).Value
+		for _, app := range apps {
+			if appID != "*" && appID != strconv.Itoa(app.ID) && appID != app.Name {
+				continue
+			}
+
+			appMetric := metrics[i]
+			if appID == "*" {
+				appMetric = withElementValue(appMetric, 4, strconv.Itoa(app.ID))
+			}
+
+			align, lag := metricDataTimeframe(m.Config)
+
+			fetch := &browserFetch{
+				appID: app.ID,
+				align: align,
+				lag:   lag,
+			}
+
+			if m.Tags["Type"] == "summary" {
+				fetch.summary = true
+			} else {
+				fetch.relativeMin = m.Namespace.Element(6).Value
+				fetch.metricStringID = m.Namespace.Element(7).Value
+			}
+
+			fetchKey := fmt.Sprintf("%d/%t/%s/%t/%s/%s", fetch.appID, fetch.align, fetch.lag, fetch.summary, fetch.relativeMin, fetch.metricStringID)
+			if _, ok := fetchesByKey[fetchKey]; !ok {
+				fetchesByKey[fetchKey] = fetch
+				fetches = append(fetches, fetch)
+			}
+
+			targets = append(targets, browserTarget{
+				metric: appMetric,
+				fetch:  fetchesByKey[fetchKey],
+				tags: map[string]string{
+					"app_id":   strconv.Itoa(app.ID),
+					"app_name": app.Name,
+				},
+			})
+		}
+	}
+
+	// Summaries and metrics missing, fetching concurrently...
+	errs := serviceWorkers(b.Workers, metrics).parallel(len(fetches), func(j int) error {
+		fetch := fetches[j]
+		if fetch.summary {
+			var err error
+			fetch.summaryData, err = b.fetchSummary(fetch.appID, fetch.align, fetch.lag)
+
+			return err
+		}
+
+		metricDataOptions, err := newMetricDataOptions(now(b.Clock), fetch.relativeMin, fetch.align, fetch.lag)
+		if err != nil {
+			return err
+		}
+
+		fetch.metricData, err = b.BrowserClient.GetApplicationMetricData(fetch.appID, []string{fetch.metricStringID}, metricDataOptions)
+
+		return err
+	})
+
+	if err := firstError(errs); err != nil {
+		return collectedMetrics, err
+	}
+
+	for _, target := range targets {
+		var metricValues map[string]interface{}
+		var sourceTime time.Time
+		if target.fetch.summary {
+			metricValues = target.fetch.summaryData.values
+			sourceTime = target.fetch.summaryData.to
+		} else {
+			timeslices, found, err := metricDataTimeslices(target.fetch.metricData, target.fetch.metricStringID)
+			if err != nil {
+				return collectedMetrics, err
+			}
+
+			if !found {
+				// Metric not found, skip reporting it and continue execution.
+				continue
+			}
+
+			metricValues = timesliceValues(timeslices[0])
+			sourceTime = timeslices[0].To
+		}
+
+		populatedMetric, err := populateMetric(target.metric, metricValues, target.tags)
+		if err != nil {
+			// Metric not found, skip reporting it and continue execution.
+			continue
+		}
+
+		collectedMetrics = append(collectedMetrics, withSourceTimestamp(populatedMetric, sourceTime))
+	}
+
+	return collectedMetrics, nil
+}
+
+// browserFetch is a fetch of a browser summary, or of the metric data of a metric name, of an application, shared by
+// the metrics requesting it.
+type browserFetch struct {
+	appID          int
+	align          bool
+	lag            time.Duration
+	summary        bool
+	relativeMin    string
+	metricStringID string
+
+	summaryData *browserSummary
+	metricData  *nr.MetricDataResponse
+}
+
+// browserTarget is a metric to report for an application, from the data of a fetch.
+type browserTarget struct {
+	metric plugin.Metric
+	fetch  *browserFetch
+	tags   map[string]string
+}
+
+// browserSummary is the browser summary of an application, and the end of the timeframe it summarizes.
+type browserSummary struct {
+	values map[string]interface{}
+	to     time.Time
+}
+
+// fetchSummary fetches the metric data the browser summary is built from, over the default timeframe aligned and
+// lagged like the other metric data, and maps it to the summary fields.
+func (b *Browser) fetchSummary(appID int, align bool, lag time.Duration) (*browserSummary, error) {
+	metricDataOptions, err := newMetricDataOptions(now(b.Clock), "*", align, lag)
+	if err != nil {
+		return nil, err
+	}
+
+	metricData, err := b.BrowserClient.GetApplicationMetricData(appID, browserSummaryNames, metricDataOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &browserSummary{
+		values: map[string]interface{}{},
+	}
+
+	values := map[string]map[string]float64{}
+	for _, md := range metricData.Metrics {
+		if len(md.Timeslices) == 0 {
+			continue
+		}
+
+		values[md.Name] = md.Timeslices[0].Values
+
+		if md.Timeslices[0].To.After(summary.to) {
+			summary.to = md.Timeslices[0].To
+		}
+	}
+
+	for field, source := range browserSummaryValues {
+		if value, ok := values[source[0]][source[1]]; ok {
+			summary.values[field] = value
+		}
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,263 @@
+package newrelic_test
+
+import (
+	"fmt"
+	"github.com/inteleon/snap-plugin-collector-newrelic/newrelic"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	nr "github.com/yfronto/newrelic"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type browserClientTestImpl struct {
+	mu sync.Mutex
+
+	appsCalls       int
+	metricDataNames [][]string
+	options         []*nr.MetricDataOptions
+}
+
+func (b *browserClientTestImpl) GetBrowserApplications() ([]nr.BrowserApplication, error) {
+	b.appsCalls++
+
+	return []nr.BrowserApplication{
+		{
+			ID:   555,
+			Name: "shop-frontend",
+		},
+	}, nil
+}
+
+func (b *browserClientTestImpl) GetApplicationMetricData(appID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.metricDataNames = append(b.metricDataNames, names)
+	b.options = append(b.options, options)
+
+	metrics := []nr.MetricData{}
+	for _, name := range names {
+		values := map[string]float64{
+			"average_response_time": 1.5,
+			"calls_per_minute":      300,
+		}
+
+		if name == "EndUser/Apdex" {
+			values = map[string]float64{
+				"score": 0.87,
+			}
+		}
+
+		metrics = append(metrics, nr.MetricData{
+			Name: name,
+			Timeslices: []nr.MetricTimeslice{
+				{
+					From:   options.From,
+					To:     options.To,
+					Values: values,
+				},
+			},
+		})
+	}
+
+	return &nr.MetricDataResponse{
+		Metrics: metrics,
+	}, nil
+}
+
+func TestGetBrowserMetricTypesSuccess(t *testing.T) {
+	b := &newrelic.Browser{}
+
+	metrics, err := b.GetMetricTypes(plugin.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLen := len(newrelic.BrowserMetrics)
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	for i, m := range metrics {
+		expectedNS := fmt.Sprintf("inteleon/newrelic/browser/%s", strings.Join(newrelic.BrowserMetrics[i].Namespace.Strings(), "/"))
+		ns := strings.Join(m.Namespace.Strings(), "/")
+
+		if ns != expectedNS {
+			t.Fatal("expected", expectedNS, "got", ns)
+		}
+	}
+}
+
+func TestCollectBrowserMetricsSuccess(t *testing.T) {
+	browserClient := &browserClientTestImpl{}
+
+	b := &newrelic.Browser{
+		BrowserClient: browserClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "browser", "application", "*", "show", "summary", "page_load_time"),
+			Tags: map[string]string{
+				"Type": "summary",
+				"Path": "PageLoadTime",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "browser", "application", "555", "show", "summary", "apdex_score"),
+			Tags: map[string]string{
+				"Type": "summary",
+				"Path": "ApdexScore",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "browser", "application", "shop-frontend", "ajax", "5", "AjaxCall/all", "calls_per_minute", "value"),
+			Tags: map[string]string{
+				"Type": "ajax",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := b.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 3 {
+		t.Fatal("expected", 3, "got", len(ret))
+	}
+
+	expected := []float64{1.5, 0.87, 300}
+	for i, e := range expected {
+		if ret[i].Data.(float64) != e {
+			t.Fatal("expected", e, "got", ret[i].Data.(float64))
+		}
+
+		if ret[i].Tags["app_name"] != "shop-frontend" {
+			t.Fatal("expected", "shop-frontend", "got", ret[i].Tags["app_name"])
+		}
+	}
+
+	if ret[0].Namespace.Element(4).Value != "555" {
+		t.Fatal("expected", "555", "got", ret[0].Namespace.Element(4).Value)
+	}
+
+	if browserClient.appsCalls != 1 {
+		t.Fatal("expected", 1, "got", browserClient.appsCalls)
+	}
+
+	if len(browserClient.metricDataNames) != 2 {
+		t.Fatal("expected", 2, "got", len(browserClient.metricDataNames))
+	}
+
+	// The summary and the metric data are fetched concurrently.
+	requestedNames := []string{}
+	for _, names := range browserClient.metricDataNames {
+		requestedNames = append(requestedNames, strings.Join(names, ","))
+	}
+	sort.Strings(requestedNames)
+
+	if requestedNames[0] != "AjaxCall/all" {
+		t.Fatal("expected", "AjaxCall/all", "got", requestedNames[0])
+	}
+}
+
+func TestCollectBrowserMetricsMetricNameSkipped(t *testing.T) {
+	browserClient := &browserClientTestImpl{}
+
+	b := &newrelic.Browser{
+		BrowserClient: browserClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "browser", "application", "555", "page_view", "5", "AjaxCall/all", "calls_per_minute", "value"),
+			Tags: map[string]string{
+				"Type": "page_view",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "browser", "application", "555", "show", "summary", "page_load_time"),
+			Tags: map[string]string{
+				"Type": "summary",
+				"Path": "PageLoadTime",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := b.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The AJAX metric name requested as a page view metric is skipped, the summary is still reported.
+	if len(ret) != 1 {
+		t.Fatal("expected", 1, "got", len(ret))
+	}
+
+	if ret[0].Namespace.Element(7).Value != "page_load_time" {
+		t.Fatal("expected", "page_load_time", "got", ret[0].Namespace.Element(7).Value)
+	}
+
+	for _, names := range browserClient.metricDataNames {
+		if strings.Join(names, ",") == "AjaxCall/all" {
+			t.Fatal("expected", "no AjaxCall/all request", "got", names)
+		}
+	}
+}
+
+func TestCollectBrowserMetricsAlignedSummarySuccess(t *testing.T) {
+	browserClient := &browserClientTestImpl{}
+
+	b := &newrelic.Browser{
+		BrowserClient: browserClient,
+		Clock: func() time.Time {
+			return time.Date(2017, 1, 1, 12, 47, 30, 0, time.UTC)
+		},
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "browser", "application", "555", "show", "summary", "page_load_time"),
+			Config:    plugin.Config{"align": true, "lag_seconds": int64(1200)},
+			Tags: map[string]string{
+				"Type": "summary",
+				"Path": "PageLoadTime",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := b.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(browserClient.options) != 1 {
+		t.Fatal("expected", 1, "got", len(browserClient.options))
+	}
+
+	// The summary timeframe is aligned and lagged like the other metric data.
+	expectedFrom := time.Date(2017, 1, 1, 11, 30, 0, 0, time.UTC)
+	expectedTo := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	if !browserClient.options[0].From.Equal(expectedFrom) || !browserClient.options[0].To.Equal(expectedTo) {
+		t.Fatal("expected", expectedFrom, expectedTo, "got", browserClient.options[0].From, browserClient.options[0].To)
+	}
+
+	// The summary is timestamped at the end of the timeframe it summarizes.
+	if len(ret) != 1 {
+		t.Fatal("expected", 1, "got", len(ret))
+	}
+
+	if !ret[0].Timestamp.Equal(expectedTo) {
+		t.Fatal("expected", expectedTo, "got", ret[0].Timestamp)
+	}
+}
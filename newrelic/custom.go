@@ -7,10 +7,19 @@ import (
 	"fmt"
 	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
 	nr "github.com/yfronto/newrelic"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// MetricDataMaxNames is the maximum number of metric names requested in a single metric data request. The names are
+// part of the request URL, larger batches are split to stay within the URL length the API accepts.
+const MetricDataMaxNames = 20
+
+// MetricDataDefaultMinutes is the timeframe, in minutes, New Relic returns metric data for when none is requested.
+const MetricDataDefaultMinutes = 30
+
 // CustomMetrics defines the available metric data metrics.
 var CustomMetrics = []Metric{
 	{
@@ -69,12 +78,109 @@ var CustomMetrics = []Metric{
 		Type: "component",
 		Unit: "float",
 	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "The application id",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "host_id",
+				Description: "The application host id",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "minutes",
+				Description: "Number of minutes to construct a relative timeframe from (now - minutes).",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "metric_name",
+				Description: "Metric name",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "value_name",
+				Description: "Value name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("value"),
+		},
+		Type: "host",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("instance"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "The application id",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "instance_id",
+				Description: "The application instance id",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "minutes",
+				Description: "Number of minutes to construct a relative timeframe from (now - minutes).",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "metric_name",
+				Description: "Metric name",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "value_name",
+				Description: "Value name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("value"),
+		},
+		Type: "instance",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("mobile"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "The mobile application id",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "minutes",
+				Description: "Number of minutes to construct a relative timeframe from (now - minutes).",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "metric_name",
+				Description: "Metric name",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "value_name",
+				Description: "Value name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("value"),
+		},
+		Type: "mobile",
+		Unit: "float",
+	},
 }
 
 // CustomClient defines the custom metrics (all metric data metrics) client.
 type CustomClient interface {
 	GetApplicationMetricData(int, []string, *nr.MetricDataOptions) (*nr.MetricDataResponse, error)
 	GetComponentMetricData(int, []string, *nr.MetricDataOptions) (*nr.MetricDataResponse, error)
+	GetApplicationHostMetricData(int, int, []string, *nr.MetricDataOptions) (*nr.MetricDataResponse, error)
+	GetApplicationInstanceMetricData(int, int, []string, *nr.MetricDataOptions) (*nr.MetricDataResponse, error)
+	GetMobileMetricData(int, []string, *nr.MetricDataOptions) (*nr.MetricDataResponse, error)
 }
 
 // CustomClientImpl is a real implementation of an CustomClient.
@@ -84,44 +190,295 @@ type CustomClientImpl struct {
 
 // GetApplicationMetricData fetches application specific metric data.
 func (cc *CustomClientImpl) GetApplicationMetricData(appID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
-	c := nr.NewClient(cc.APIKey)
+	c := newNRClient(cc.APIKey)
 
 	return c.GetApplicationMetricData(appID, names, options)
 }
 
 // GetComponentMetricData fetches component specific metric data.
 func (cc *CustomClientImpl) GetComponentMetricData(componentID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
-	c := nr.NewClient(cc.APIKey)
+	c := newNRClient(cc.APIKey)
 
 	return c.GetComponentMetricData(componentID, names, options)
 }
 
+// GetApplicationHostMetricData fetches application host specific metric data.
+func (cc *CustomClientImpl) GetApplicationHostMetricData(appID int, hostID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	r := newRESTClient(cc.APIKey)
+
+	return r.getMetricData(fmt.Sprintf("applications/%d/hosts/%d/metrics/data.json", appID, hostID), names, options)
+}
+
+// GetApplicationInstanceMetricData fetches application instance specific metric data.
+func (cc *CustomClientImpl) GetApplicationInstanceMetricData(appID int, instanceID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	r := newRESTClient(cc.APIKey)
+
+	return r.getMetricData(fmt.Sprintf("applications/%d/instances/%d/metrics/data.json", appID, instanceID), names, options)
+}
+
+// GetMobileMetricData fetches mobile application specific metric data.
+func (cc *CustomClientImpl) GetMobileMetricData(mobileAppID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	r := newRESTClient(cc.APIKey)
+
+	return r.getMetricData(fmt.Sprintf("mobile_applications/%d/metrics/data.json", mobileAppID), names, options)
+}
+
+// MetricNamesClient is the interface every client able to list plugin components and the metric names of
+// applications and plugin components needs to implement.
+type MetricNamesClient interface {
+	GetComponents() ([]nr.Component, error)
+	GetComponentMetrics(int) ([]nr.Metric, error)
+	GetApplicationMetrics(int, string) ([]nr.Metric, error)
+}
+
+// MetricNamesClientImpl is a real implementation of a MetricNamesClient.
+type MetricNamesClientImpl struct {
+	APIKey string
+}
+
+// GetComponents fetches all plugin components visible to the API key.
+func (mc *MetricNamesClientImpl) GetComponents() ([]nr.Component, error) {
+	c := newNRClient(mc.APIKey)
+
+	return c.GetComponents(&nr.ComponentsOptions{})
+}
+
+// GetComponentMetrics fetches the metric names, and their value names, of a plugin component.
+func (mc *MetricNamesClientImpl) GetComponentMetrics(componentID int) ([]nr.Metric, error) {
+	c := newNRClient(mc.APIKey)
+
+	return c.GetComponentMetrics(componentID, &nr.MetricsOptions{})
+}
+
+// GetApplicationMetrics fetches the metric names, and their value names, of an application. Only the metric names
+// starting with the given prefix are fetched, an empty prefix fetches all.
+func (mc *MetricNamesClientImpl) GetApplicationMetrics(appID int, prefix string) ([]nr.Metric, error) {
+	c := newNRClient(mc.APIKey)
+
+	return c.GetApplicationMetrics(appID, &nr.MetricsOptions{Name: prefix})
+}
+
 // Custom represents the custom metric data metrics available from New Relic.
 type Custom struct {
-	CustomClient CustomClient
+	CustomClient      CustomClient
+	MetricNamesClient MetricNamesClient
+	MobileClient      MobileClient
+	Applications      *Applications
+	Workers           *Workers
+	Clock             func() time.Time
 }
 
-// NewCustom creates and returns a new Custom object with a configured CustomClient.
-func NewCustom(apiKey string) Service {
+// NewCustom creates and returns a new Custom object with the given CustomClient, MetricNamesClient and MobileClient,
+// a shared applications lookup and the workers of the collection, if any.
+func NewCustom(client CustomClient, metricNames MetricNamesClient, mobile MobileClient, apps *Applications, workers *Workers) Service {
 	return &Custom{
-		CustomClient: &CustomClientImpl{
-			APIKey: apiKey,
-		},
+		CustomClient:      client,
+		MetricNamesClient: metricNames,
+		MobileClient:      mobile,
+		Applications:      apps,
+		Workers:           workers,
 	}
 }
 
 // GetMetricTypes returns the available metric types.
-func (c *Custom) GetMetricTypes(_ plugin.Config) ([]plugin.Metric, error) {
+// When an API key is configured, the component metric types are also returned for every metric name and value name
+// of every plugin component visible to it. The same goes for the applications set by the metric_name_apps config,
+// for the metric names found, optionally narrowed by the metric_name_filter prefix. The generic application metric
+// types are then only returned for the other applications. When discovering the components, applications or metric
+// names fails only the generic metric types are returned.
+func (c *Custom) GetMetricTypes(cfg plugin.Config) ([]plugin.Metric, error) {
 	ns := plugin.NewNamespace("inteleon", "newrelic", "metric")
 
-	return metricTypes(ns, CustomMetrics)
+	if _, err := cfg.GetString("api_key"); err != nil || c.MetricNamesClient == nil {
+		// No API key, no components or metric names to discover.
+		return metricTypes(ns, CustomMetrics)
+	}
+
+	metricNameApps := []string{}
+	if apps, err := cfg.GetString("metric_name_apps"); err == nil {
+		for _, idOrName := range strings.Split(apps, ",") {
+			if idOrName = strings.TrimSpace(idOrName); idOrName != "" {
+				metricNameApps = append(metricNameApps, idOrName)
+			}
+		}
+	}
+
+	metricNameFilter, _ := cfg.GetString("metric_name_filter")
+
+	// The generic application metric types match any metric name, a misspelled metric name of an application listed
+	// by the metric_name_apps config would match them as well. They're listed per application instead, for the
+	// applications not listed only.
+	genericPerApp := len(metricNameApps) > 0 && c.Applications != nil
+
+	genericMetrics := CustomMetrics
+	if genericPerApp {
+		genericMetrics = customMetricsExceptType("application")
+	}
+
+	metrics, err := metricTypes(ns, genericMetrics)
+	if err != nil {
+		return metrics, err
+	}
+
+	components, err := c.MetricNamesClient.GetComponents()
+	if err != nil {
+		// Components not discoverable, the generic metric types still work.
+		return metricTypes(ns, CustomMetrics)
+	}
+
+	workers := c.Workers
+	if workers == nil {
+		workers = NewWorkers(configWorkers(cfg))
+	}
+
+	// Metric names missing, fetching them concurrently per component and listed application...
+	componentMetrics := make([][]nr.Metric, len(components))
+	listedAppIDs := make([]int, len(metricNameApps))
+	appMetrics := make([][]nr.Metric, len(metricNameApps))
+	errs := workers.parallel(len(components)+len(metricNameApps), func(j int) error {
+		var err error
+		if j < len(components) {
+			componentMetrics[j], err = c.MetricNamesClient.GetComponentMetrics(components[j].ID)
+
+			return err
+		}
+
+		j -= len(components)
+
+		listedAppIDs[j], err = c.Applications.ResolveID(metricNameApps[j])
+		if err != nil {
+			return err
+		}
+
+		appMetrics[j], err = c.MetricNamesClient.GetApplicationMetrics(listedAppIDs[j], metricNameFilter)
+
+		return err
+	})
+
+	if err := firstError(errs); err != nil {
+		// Metric names not discoverable, the generic metric types still work.
+		return metricTypes(ns, CustomMetrics)
+	}
+
+	for j, component := range components {
+		nameMetrics, err := metricNameTypes(
+			ns,
+			withNamespaceValue(customMetricsOfType("component"), "component_id", strconv.Itoa(component.ID)),
+			componentMetrics[j],
+		)
+		if err != nil {
+			return metrics, err
+		}
+
+		for i := range nameMetrics {
+			metrics = append(metrics, nameMetrics[i])
+		}
+	}
+
+	for j, idOrName := range metricNameApps {
+		// An application listed by name is requested by name in tasks as well.
+		for _, appElem := range appendMissing([]string{strconv.Itoa(listedAppIDs[j])}, idOrName) {
+			nameMetrics, err := metricNameTypes(
+				ns,
+				withNamespaceValue(customMetricsOfType("application"), "app_id", appElem),
+				appMetrics[j],
+			)
+			if err != nil {
+				return metrics, err
+			}
+
+			for i := range nameMetrics {
+				metrics = append(metrics, nameMetrics[i])
+			}
+		}
+	}
+
+	if !genericPerApp {
+		return metrics, nil
+	}
+
+	apps, err := c.Applications.List()
+	if err != nil {
+		return metricTypes(ns, CustomMetrics)
+	}
+
+	for _, app := range apps {
+		if containsInt(listedAppIDs, app.ID) {
+			continue
+		}
+
+		for _, appElem := range []string{strconv.Itoa(app.ID), app.Name} {
+			appMetrics, err := metricTypes(ns, withNamespaceValue(customMetricsOfType("application"), "app_id", appElem))
+			if err != nil {
+				return metrics, err
+			}
+
+			for i := range appMetrics {
+				metrics = append(metrics, appMetrics[i])
+			}
+		}
+	}
+
+	return metrics, nil
+}
+
+// findMobileAppID returns the id of the mobile application with the given name.
+func findMobileAppID(apps []MobileApplication, name string) (int, error) {
+	for _, app := range apps {
+		if app.Name == name {
+			return app.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Mobile application not found: %s", name)
+}
+
+// metricNameTypes returns the metric types of the metrics list for every metric name and value name.
+func metricNameTypes(ns plugin.Namespace, metricsList []Metric, metricNames []nr.Metric) ([]plugin.Metric, error) {
+	metrics := []plugin.Metric{}
+
+	for _, metricName := range metricNames {
+		nameList := withNamespaceValue(metricsList, "metric_name", metricName.Name)
+
+		for _, valueName := range metricName.Values {
+			valueMetrics, err := metricTypes(ns, withNamespaceValue(nameList, "value_name", valueName))
+			if err != nil {
+				return metrics, err
+			}
+
+			for i := range valueMetrics {
+				metrics = append(metrics, valueMetrics[i])
+			}
+		}
+	}
+
+	return metrics, nil
+}
+
+// metricDataRequest is a metric data request for all the requested metric names of an entity over a timeframe.
+type metricDataRequest struct {
+	metricType  string
+	id          int
+	subID       int
+	relativeMin string
+	align       bool
+	lag         time.Duration
+	summarize   bool
+	period      int
+	names       []string
 }
 
 // CollectMetrics fetches the requested metric data metrics and returns them.
+// The metric names are requested in batches per entity and timeframe, rather than one request per metric name.
 func (c *Custom) CollectMetrics(metrics []plugin.Metric) ([]plugin.Metric, error) {
 	collectedMetrics := []plugin.Metric{}
 
-	metricResponses := map[string]map[int]map[string]*nr.MetricDataResponse{}
+	requests := []*metricDataRequest{}
+	requestsByKey := map[string]*metricDataRequest{}
+	metricRequests := map[int]*metricDataRequest{}
+	metricNames := map[int]string{}
+	metricNameElems := map[int]int{}
+	var mobileApps []MobileApplication
 	for i, m := range metrics {
 		if m.Namespace.Element(2).Value != "metric" {
 			continue
@@ -130,96 +487,404 @@ func (c *Custom) CollectMetrics(metrics []plugin.Metric) ([]plugin.Metric, error
 		metricType := m.Tags["Type"]
 		id := m.Namespace.Element(4)
 
-		idInt, err := strconv.Atoi(id.Value)
-		if err != nil {
-			return collectedMetrics, err
+		var idInt int
+		var err error
+		if metricType == "component" {
+			idInt, err = strconv.Atoi(id.Value)
+		} else if metricType == "mobile" {
+			idInt, err = strconv.Atoi(id.Value)
+			if err != nil && c.MobileClient != nil {
+				// Mobile metrics can be requested by mobile application name as well.
+				if mobileApps == nil {
+					// Mobile applications missing, fetching...
+					mobileApps, err = c.MobileClient.GetMobileApplications()
+					if err != nil {
+						return collectedMetrics, err
+					}
+				}
+
+				idInt, err = findMobileAppID(mobileApps, id.Value)
+			}
+		} else {
+			// Application metrics can be requested by application name as well.
+			idInt, err = c.Applications.ResolveID(id.Value)
 		}
 
-		relativeMin := m.Namespace.Element(5).Value
-		metricStringID := m.Namespace.Element(6).Value
-		metricDataOptions := &nr.MetricDataOptions{
-			Summarize: true,
+		if err != nil {
+			return collectedMetrics, err
 		}
 
-		if relativeMin != "*" {
-			relativeMinInt, err := strconv.Atoi(relativeMin)
+		// Host and instance metrics have an additional id element following the app id.
+		subIDInt := 0
+		elemOffset := 0
+		if metricType == "host" || metricType == "instance" {
+			subIDInt, err = strconv.Atoi(m.Namespace.Element(5).Value)
 			if err != nil {
 				return collectedMetrics, err
 			}
 
-			metricDataOptions.From = time.Now().UTC().Add(-(time.Duration(relativeMinInt) * time.Minute))
-			metricDataOptions.To = time.Now().UTC()
+			elemOffset = 1
 		}
 
-		if _, ok := metricResponses[metricType]; !ok {
-			metricResponses[metricType] = map[int]map[string]*nr.MetricDataResponse{}
+		relativeMin := m.Namespace.Element(5 + elemOffset).Value
+		metricStringID := m.Namespace.Element(6 + elemOffset).Value
+
+		// The summarized value is reported unless the full timeslice series is requested.
+		summarize := true
+		if summarizeConfig, err := m.Config.GetBool("summarize"); err == nil {
+			summarize = summarizeConfig
+		}
+
+		// The timeslice period, in seconds, is left to New Relic unless configured.
+		period, _ := m.Config.GetInt("period")
+
+		align, lag := metricDataTimeframe(m.Config)
+
+		requestKey := fmt.Sprintf("%s/%d/%d/%s/%t/%s/%t/%d", metricType, idInt, subIDInt, relativeMin, align, lag, summarize, period)
+		if _, ok := requestsByKey[requestKey]; !ok {
+			requestsByKey[requestKey] = &metricDataRequest{
+				metricType:  metricType,
+				id:          idInt,
+				subID:       subIDInt,
+				relativeMin: relativeMin,
+				align:       align,
+				lag:         lag,
+				summarize:   summarize,
+				period:      int(period),
+			}
+
+			requests = append(requests, requestsByKey[requestKey])
 		}
 
-		if _, ok := metricResponses[metricType][idInt][metricStringID]; !ok {
-			// Metrics missing, fetching...
-			var fetchMetricData *nr.MetricDataResponse
-			var err error
+		request := requestsByKey[requestKey]
+		request.names = appendMissing(request.names, metricStringID)
 
-			switch metricType {
-			case "application":
-				fetchMetricData, err = c.CustomClient.GetApplicationMetricData(
-					idInt,
-					[]string{metricStringID},
-					metricDataOptions,
-				)
+		metricRequests[i] = request
+		metricNames[i] = metricStringID
+		metricNameElems[i] = 6 + elemOffset
+	}
+
+	// Metrics missing, fetching the requests concurrently...
+	fetchedMetricData := make([]*nr.MetricDataResponse, len(requests))
+	errs := serviceWorkers(c.Workers, metrics).parallel(len(requests), func(j int) error {
+		var err error
+		fetchedMetricData[j], err = c.fetchMetricData(requests[j])
 
-				break
-			case "component":
-				fetchMetricData, err = c.CustomClient.GetComponentMetricData(
-					idInt,
-					[]string{metricStringID},
-					metricDataOptions,
-				)
+		return err
+	})
 
-				break
+	if err := firstError(errs); err != nil {
+		return collectedMetrics, err
+	}
+
+	metricResponses := map[*metricDataRequest]*nr.MetricDataResponse{}
+	for j, request := range requests {
+		metricResponses[request] = fetchedMetricData[j]
+	}
+
+	for i := range metrics {
+		request, ok := metricRequests[i]
+		if !ok {
+			continue
+		}
+
+		matches, err := metricDataMatches(metricResponses[request], metricNames[i])
+		if err != nil {
+			return collectedMetrics, err
+		}
+
+		tags := c.metricTags(request.metricType, request.id, request.subID)
+		for _, metricData := range matches {
+			timeslices := metricData.Timeslices
+			if len(timeslices) == 0 {
+				// Metric not found, skip reporting it and continue execution.
+				continue
 			}
 
-			if err != nil {
-				return collectedMetrics, err
+			if request.summarize {
+				// A summarized response holds a single timeslice covering the whole timeframe.
+				timeslices = timeslices[:1]
 			}
 
-			if _, ok := metricResponses[metricType][idInt]; !ok {
-				metricResponses[metricType][idInt] = map[string]*nr.MetricDataResponse{}
+			nameMetric := metrics[i]
+			if metricData.Name != metricNames[i] {
+				// A wildcard metric name is reported under the name of every metric matching it.
+				nameMetric = withElementValue(nameMetric, metricNameElems[i], metricData.Name)
 			}
 
-			metricResponses[metricType][idInt][metricStringID] = fetchMetricData
+			for _, timeslice := range timeslices {
+				populatedMetric, err := populateMetric(nameMetric, timesliceValues(timeslice), tags)
+				if err != nil {
+					return collectedMetrics, err
+				}
+
+				if !request.summarize && !timeslice.From.IsZero() {
+					// Every timeslice of a series is reported at the start of the timeslice, regardless of the
+					// timestamp_source config, or the series would collapse into a single point in time.
+					populatedMetric.Timestamp = timeslice.From.UTC()
+					collectedMetrics = append(collectedMetrics, populatedMetric)
+
+					continue
+				}
+
+				// A summarized value describes the timeframe ending at the end of the timeslice.
+				collectedMetrics = append(collectedMetrics, withSourceTimestamp(populatedMetric, timeslice.To))
+			}
 		}
+	}
 
-		metricData := metricResponses[metricType][idInt][metricStringID]
+	return collectedMetrics, nil
+}
 
-		numberOfMetricsFound := len(metricData.Metrics)
-		if numberOfMetricsFound != 1 {
-			// Metric not found, skip reporting it and continue execution.
-			continue
+// fetchMetricData fetches the metric data of all the metric names of the request, MetricDataMaxNames names at a time,
+// and merges the responses into one.
+func (c *Custom) fetchMetricData(request *metricDataRequest) (*nr.MetricDataResponse, error) {
+	metricDataOptions, err := newMetricDataOptions(now(c.Clock), request.relativeMin, request.align, request.lag)
+	if err != nil {
+		return nil, err
+	}
+
+	metricDataOptions.Summarize = request.summarize
+	metricDataOptions.Period = request.period
+
+	metricData := &nr.MetricDataResponse{
+		Metrics: []nr.MetricData{},
+	}
+
+	for start := 0; start < len(request.names); start += MetricDataMaxNames {
+		end := start + MetricDataMaxNames
+		if end > len(request.names) {
+			end = len(request.names)
 		}
 
-		firstMetric := metricData.Metrics[0]
-		if firstMetric.Name != metricStringID {
-			return collectedMetrics, fmt.Errorf(
-				"Metric name mismatch! Requested metric name: %s. Metric name in the received payload: %s.",
-				metricStringID,
-				firstMetric.Name,
+		names := request.names[start:end]
+
+		var chunkMetricData *nr.MetricDataResponse
+		var err error
+
+		switch request.metricType {
+		case "application":
+			chunkMetricData, err = c.CustomClient.GetApplicationMetricData(
+				request.id,
+				names,
+				metricDataOptions,
+			)
+
+			break
+		case "component":
+			chunkMetricData, err = c.CustomClient.GetComponentMetricData(
+				request.id,
+				names,
+				metricDataOptions,
 			)
+
+			break
+		case "host":
+			chunkMetricData, err = c.CustomClient.GetApplicationHostMetricData(
+				request.id,
+				request.subID,
+				names,
+				metricDataOptions,
+			)
+
+			break
+		case "instance":
+			chunkMetricData, err = c.CustomClient.GetApplicationInstanceMetricData(
+				request.id,
+				request.subID,
+				names,
+				metricDataOptions,
+			)
+
+			break
+		case "mobile":
+			chunkMetricData, err = c.CustomClient.GetMobileMetricData(
+				request.id,
+				names,
+				metricDataOptions,
+			)
+
+			break
+		default:
+			err = fmt.Errorf("Unknown metric type: %s", request.metricType)
 		}
 
-		metricValues := firstMetric.Timeslices[0].Values
-		castValues := map[string]interface{}{}
-		for ci := range metricValues {
-			castValues[ci] = metricValues[ci]
+		if err != nil {
+			return nil, err
 		}
 
-		populatedMetric, err := populateMetric(metrics[i], castValues)
+		metricData.From = chunkMetricData.From
+		metricData.To = chunkMetricData.To
+		metricData.MetricsFound = append(metricData.MetricsFound, chunkMetricData.MetricsFound...)
+		metricData.MetricsNotFound = append(metricData.MetricsNotFound, chunkMetricData.MetricsNotFound...)
+		metricData.Metrics = append(metricData.Metrics, chunkMetricData.Metrics...)
+	}
+
+	return metricData, nil
+}
+
+// customMetricsOfType returns the metric data metrics of the given type.
+func customMetricsOfType(metricType string) []Metric {
+	ret := []Metric{}
+
+	for _, m := range CustomMetrics {
+		if m.Type == metricType {
+			ret = append(ret, m)
+		}
+	}
+
+	return ret
+}
+
+// customMetricsExceptType returns the metric data metrics of every type but the given one.
+func customMetricsExceptType(metricType string) []Metric {
+	ret := []Metric{}
+
+	for _, m := range CustomMetrics {
+		if m.Type != metricType {
+			ret = append(ret, m)
+		}
+	}
+
+	return ret
+}
+
+// metricTags returns the tags describing the entity a metric data metric belongs to.
+func (c *Custom) metricTags(metricType string, id int, subID int) map[string]string {
+	if metricType == "component" {
+		return map[string]string{
+			"component_id": strconv.Itoa(id),
+		}
+	}
+
+	if metricType == "mobile" {
+		return map[string]string{
+			"mobile_app_id": strconv.Itoa(id),
+		}
+	}
+
+	// The application info might not be available, fall back to the id only.
+	tags := map[string]string{
+		"app_id": strconv.Itoa(id),
+	}
+
+	if c.Applications != nil {
+		if app, err := c.Applications.Find(strconv.Itoa(id)); err == nil {
+			tags = applicationTags(app)
+		}
+	}
+
+	if metricType == "host" || metricType == "instance" {
+		tags[metricType+"_id"] = strconv.Itoa(subID)
+	}
+
+	return tags
+}
+
+// now returns the current time of the clock, or of the system when the clock isn't set.
+func now(clock func() time.Time) time.Time {
+	if clock == nil {
+		return time.Now()
+	}
+
+	return clock()
+}
+
+// newMetricDataOptions returns the metric data options for a relative timeframe of the given number of minutes,
+// ending lag before now. An aligned timeframe ends at the last boundary of its own length, e.g. the last whole minute
+// for 1 minute or the last whole hour for 60 minutes, so consecutive timeframes don't overlap.
+// A wildcard leaves the timeframe to New Relic, which defaults to the last 30 minutes, unless it's aligned or lagged.
+func newMetricDataOptions(now time.Time, relativeMin string, align bool, lag time.Duration) (*nr.MetricDataOptions, error) {
+	metricDataOptions := &nr.MetricDataOptions{
+		Summarize: true,
+	}
+
+	timeframe := MetricDataDefaultMinutes * time.Minute
+	if relativeMin != "*" {
+		relativeMinInt, err := strconv.Atoi(relativeMin)
 		if err != nil {
-			return collectedMetrics, err
+			return nil, err
 		}
 
-		collectedMetrics = append(collectedMetrics, populatedMetric)
+		timeframe = time.Duration(relativeMinInt) * time.Minute
+	} else if !align && lag == 0 {
+		return metricDataOptions, nil
 	}
 
-	return collectedMetrics, nil
+	to := now.UTC().Add(-lag)
+	if align {
+		to = to.Truncate(timeframe)
+	}
+
+	metricDataOptions.From = to.Add(-timeframe)
+	metricDataOptions.To = to
+
+	return metricDataOptions, nil
+}
+
+// metricDataTimeframe returns whether the metric data timeframes of the metric are aligned, and their lag.
+func metricDataTimeframe(cfg plugin.Config) (bool, time.Duration) {
+	align, _ := cfg.GetBool("align")
+	lagSeconds, _ := cfg.GetInt("lag_seconds")
+
+	return align, time.Duration(lagSeconds) * time.Second
+}
+
+// metricDataTimeslices returns the timeslices of a single metric in a metric data response and whether the metric
+// was found at all.
+func metricDataTimeslices(metricData *nr.MetricDataResponse, metricName string) ([]nr.MetricTimeslice, bool, error) {
+	matches, err := metricDataMatches(metricData, metricName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(matches) == 0 || len(matches[0].Timeslices) == 0 {
+		return nil, false, nil
+	}
+
+	return matches[0].Timeslices, true, nil
+}
+
+// metricDataMatches returns the metrics of a metric data response matching the requested metric name. New Relic
+// answers a metric name holding a wildcard, e.g. GC/*, with the metrics of every matching name, other metric names
+// only match their own metric. A metric name missing from a response holding other metrics is an error, unless the
+// response reports it as not found.
+func metricDataMatches(metricData *nr.MetricDataResponse, metricName string) ([]nr.MetricData, error) {
+	pattern := regexp.MustCompile("^" + strings.Replace(regexp.QuoteMeta(metricName), `\*`, ".*", -1) + "$")
+
+	matches := []nr.MetricData{}
+	receivedNames := []string{}
+	for _, metric := range metricData.Metrics {
+		if pattern.MatchString(metric.Name) {
+			matches = append(matches, metric)
+		}
+
+		receivedNames = append(receivedNames, metric.Name)
+	}
+
+	if len(matches) > 0 || len(receivedNames) == 0 || strings.Contains(metricName, "*") {
+		return matches, nil
+	}
+
+	for _, notFound := range metricData.MetricsNotFound {
+		if notFound == metricName {
+			return matches, nil
+		}
+	}
+
+	return nil, fmt.Errorf(
+		"Metric name mismatch! Requested metric name: %s. Metric name in the received payload: %s.",
+		metricName,
+		strings.Join(receivedNames, ", "),
+	)
+}
+
+// timesliceValues returns the values of a timeslice keyed by value name.
+func timesliceValues(timeslice nr.MetricTimeslice) map[string]interface{} {
+	castValues := map[string]interface{}{}
+	for ci := range timeslice.Values {
+		castValues[ci] = timeslice.Values[ci]
+	}
+
+	return castValues
 }
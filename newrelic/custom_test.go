@@ -5,18 +5,30 @@ import (
 	"github.com/inteleon/snap-plugin-collector-newrelic/newrelic"
 	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
 	nr "github.com/yfronto/newrelic"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type customClientTestImpl struct {
+	mu sync.Mutex
+
 	metricDataAppIDs         []int
 	metricDataAppNames       map[int][]string
 	metricDataComponentIDs   []int
 	metricDataComponentNames map[int][]string
+	metricDataHostIDs        [][2]int
+	metricDataInstanceIDs    [][2]int
+	metricDataMobileIDs      []int
+	metricsNotFound          []string
 }
 
 func (c *customClientTestImpl) GetApplicationMetricData(appID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.metricDataAppIDs = append(c.metricDataAppIDs, appID)
 
 	if len(c.metricDataAppNames) == 0 {
@@ -29,6 +41,7 @@ func (c *customClientTestImpl) GetApplicationMetricData(appID int, names []strin
 	}
 
 	return &nr.MetricDataResponse{
+		MetricsNotFound: c.metricsNotFound,
 		Metrics: []nr.MetricData{
 			{
 				Name: "hax",
@@ -46,6 +59,9 @@ func (c *customClientTestImpl) GetApplicationMetricData(appID int, names []strin
 }
 
 func (c *customClientTestImpl) GetComponentMetricData(componentID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.metricDataComponentIDs = append(c.metricDataComponentIDs, componentID)
 
 	if len(c.metricDataComponentNames) == 0 {
@@ -74,6 +90,142 @@ func (c *customClientTestImpl) GetComponentMetricData(componentID int, names []s
 	}, nil
 }
 
+func (c *customClientTestImpl) GetApplicationHostMetricData(appID int, hostID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.metricDataHostIDs = append(c.metricDataHostIDs, [2]int{appID, hostID})
+
+	return &nr.MetricDataResponse{
+		Metrics: []nr.MetricData{
+			{
+				Name: "Memory/Physical",
+				Timeslices: []nr.MetricTimeslice{
+					{
+						Values: map[string]float64{
+							"total_used_mb": 512,
+						},
+					},
+				},
+			},
+			{
+				Name: "GC/PS Scavenge",
+				Timeslices: []nr.MetricTimeslice{
+					{
+						Values: map[string]float64{
+							"call_count": 12,
+						},
+					},
+				},
+			},
+			{
+				Name: "GC/PS MarkSweep",
+				Timeslices: []nr.MetricTimeslice{
+					{
+						Values: map[string]float64{
+							"call_count": 3,
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (c *customClientTestImpl) GetApplicationInstanceMetricData(appID int, instanceID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.metricDataInstanceIDs = append(c.metricDataInstanceIDs, [2]int{appID, instanceID})
+
+	return &nr.MetricDataResponse{
+		Metrics: []nr.MetricData{
+			{
+				Name: "Memory/Physical",
+				Timeslices: []nr.MetricTimeslice{
+					{
+						Values: map[string]float64{
+							"total_used_mb": 256,
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (c *customClientTestImpl) GetMobileMetricData(mobileAppID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.metricDataMobileIDs = append(c.metricDataMobileIDs, mobileAppID)
+
+	return &nr.MetricDataResponse{
+		Metrics: []nr.MetricData{
+			{
+				Name: "Mobile/Crash/All",
+				Timeslices: []nr.MetricTimeslice{
+					{
+						Values: map[string]float64{
+							"call_count": 3,
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+type metricNamesClientTestImpl struct {
+	mu sync.Mutex
+
+	metricsComponentIDs []int
+	metricsAppIDs       []int
+	metricsPrefixes     []string
+}
+
+func (cc *metricNamesClientTestImpl) GetComponents() ([]nr.Component, error) {
+	return []nr.Component{
+		{
+			ID:   4242,
+			Name: "gorelic",
+		},
+	}, nil
+}
+
+func (cc *metricNamesClientTestImpl) GetComponentMetrics(componentID int) ([]nr.Metric, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.metricsComponentIDs = append(cc.metricsComponentIDs, componentID)
+
+	return []nr.Metric{
+		{
+			Name:   "Component/Runtime/System/Threads[Threads]",
+			Values: []string{"average_value", "max_value"},
+		},
+		{
+			Name:   "Component/Runtime/General/NOGoroutines[goroutines]",
+			Values: []string{"average_value"},
+		},
+	}, nil
+}
+
+func (cc *metricNamesClientTestImpl) GetApplicationMetrics(appID int, prefix string) ([]nr.Metric, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.metricsAppIDs = append(cc.metricsAppIDs, appID)
+	cc.metricsPrefixes = append(cc.metricsPrefixes, prefix)
+
+	return []nr.Metric{
+		{
+			Name:   "External/api.github.com/all",
+			Values: []string{"average_response_time", "calls_per_minute"},
+		},
+	}, nil
+}
+
 func TestGetCustomMetricTypesSuccess(t *testing.T) {
 	c := &newrelic.Custom{}
 
@@ -99,6 +251,120 @@ func TestGetCustomMetricTypesSuccess(t *testing.T) {
 	}
 }
 
+func TestGetCustomMetricTypesWithComponents(t *testing.T) {
+	metricNamesClient := &metricNamesClientTestImpl{}
+
+	c := &newrelic.Custom{
+		MetricNamesClient: metricNamesClient,
+	}
+
+	metrics, err := c.GetMetricTypes(plugin.Config{"api_key": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Static metrics plus one metric per component metric value name.
+	expectedLen := len(newrelic.CustomMetrics) + 3
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	expectedNS := []string{
+		"inteleon|newrelic|metric|component|4242|*|Component/Runtime/System/Threads[Threads]|average_value|value",
+		"inteleon|newrelic|metric|component|4242|*|Component/Runtime/System/Threads[Threads]|max_value|value",
+		"inteleon|newrelic|metric|component|4242|*|Component/Runtime/General/NOGoroutines[goroutines]|average_value|value",
+	}
+	for i, e := range expectedNS {
+		ns := strings.Join(metrics[len(newrelic.CustomMetrics)+i].Namespace.Strings(), "|")
+		if ns != e {
+			t.Fatal("expected", e, "got", ns)
+		}
+	}
+
+	if len(metricNamesClient.metricsComponentIDs) != 1 || metricNamesClient.metricsComponentIDs[0] != 4242 {
+		t.Fatal("expected", []int{4242}, "got", metricNamesClient.metricsComponentIDs)
+	}
+}
+
+type failingMetricNamesClientTestImpl struct {
+	metricNamesClientTestImpl
+}
+
+func (cc *failingMetricNamesClientTestImpl) GetComponents() ([]nr.Component, error) {
+	return nil, fmt.Errorf("New Relic API request failed with status 500: oops")
+}
+
+func TestGetCustomMetricTypesComponentsFailure(t *testing.T) {
+	c := &newrelic.Custom{
+		MetricNamesClient: &failingMetricNamesClientTestImpl{},
+	}
+
+	metrics, err := c.GetMetricTypes(plugin.Config{"api_key": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(metrics) != len(newrelic.CustomMetrics) {
+		t.Fatal("expected", len(newrelic.CustomMetrics), "got", len(metrics))
+	}
+}
+
+func TestGetCustomMetricTypesWithMetricNames(t *testing.T) {
+	metricNamesClient := &metricNamesClientTestImpl{}
+
+	c := &newrelic.Custom{
+		MetricNamesClient: metricNamesClient,
+		Applications:      newrelic.NewApplications(&apmClientTestImpl{}),
+	}
+
+	metrics, err := c.GetMetricTypes(plugin.Config{
+		"api_key":            "secret",
+		"metric_name_apps":   "hax",
+		"metric_name_filter": "External/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The metric names found are listed per value name for the listed application, by id and by name. The generic
+	// application metric is listed for the other application only, by id and by name.
+	expectedLen := len(newrelic.CustomMetrics) - 1 + 3 + 4 + 2
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	expectedNS := []string{
+		"inteleon|newrelic|metric|application|1337|*|External/api.github.com/all|average_response_time|value",
+		"inteleon|newrelic|metric|application|1337|*|External/api.github.com/all|calls_per_minute|value",
+		"inteleon|newrelic|metric|application|hax|*|External/api.github.com/all|average_response_time|value",
+		"inteleon|newrelic|metric|application|hax|*|External/api.github.com/all|calls_per_minute|value",
+		"inteleon|newrelic|metric|application|1234|*|*|*|value",
+		"inteleon|newrelic|metric|application|leet|*|*|*|value",
+	}
+	for i, e := range expectedNS {
+		ns := strings.Join(metrics[len(metrics)-len(expectedNS)+i].Namespace.Strings(), "|")
+		if ns != e {
+			t.Fatal("expected", e, "got", ns)
+		}
+	}
+
+	for _, m := range metrics {
+		if m.Tags["Type"] == "application" && m.Namespace.Element(4).Value == "*" {
+			t.Fatal("expected", "no generic application metric for every application", "got", m.Namespace.Strings())
+		}
+	}
+
+	if fmt.Sprint(metricNamesClient.metricsAppIDs) != fmt.Sprint([]int{1337}) {
+		t.Fatal("expected", []int{1337}, "got", metricNamesClient.metricsAppIDs)
+	}
+
+	for _, prefix := range metricNamesClient.metricsPrefixes {
+		if prefix != "External/" {
+			t.Fatal("expected", "External/", "got", prefix)
+		}
+	}
+}
+
 func TestCollectCustomMetricsSuccess(t *testing.T) {
 	customClient := &customClientTestImpl{}
 
@@ -151,8 +417,17 @@ func TestCollectCustomMetricsSuccess(t *testing.T) {
 		t.Fatal("expected", 13.37, "got", ret[2].Data.(float64))
 	}
 
-	if len(customClient.metricDataAppIDs) != 1 {
-		t.Fatal("expected", 1, "got", len(customClient.metricDataAppIDs))
+	if ret[0].Tags["app_id"] != "1337" {
+		t.Fatal("expected", "1337", "got", ret[0].Tags["app_id"])
+	}
+
+	if ret[2].Tags["component_id"] != "31337" {
+		t.Fatal("expected", "31337", "got", ret[2].Tags["component_id"])
+	}
+
+	// The application metrics are requested over different timeframes, one request each.
+	if len(customClient.metricDataAppIDs) != 2 {
+		t.Fatal("expected", 2, "got", len(customClient.metricDataAppIDs))
 	}
 
 	if customClient.metricDataAppIDs[0] != 1337 {
@@ -227,6 +502,47 @@ func TestCollectCustomMetricsMetricNameNotFoundFailure(t *testing.T) {
 	}
 }
 
+func TestCollectCustomMetricsMetricNameReportedNotFoundSuccess(t *testing.T) {
+	customClient := &customClientTestImpl{
+		metricsNotFound: []string{"h4x"},
+	}
+
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "1337", "*", "hax", "average_response_time", "value"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "1337", "*", "h4x", "average_response_time", "value"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The metric name New Relic reports as not found is skipped, the other metric of the batch is still reported.
+	if len(ret) != 1 {
+		t.Fatal("expected", 1, "got", len(ret))
+	}
+
+	if ret[0].Namespace.Element(6).Value != "hax" {
+		t.Fatal("expected", "hax", "got", ret[0].Namespace.Element(6).Value)
+	}
+}
+
 func TestCollectCustomMetricsMetricValueNameNotFoundFailure(t *testing.T) {
 	customClient := &customClientTestImpl{}
 
@@ -261,3 +577,798 @@ func TestCollectCustomMetricsMetricValueNameNotFoundFailure(t *testing.T) {
 		t.Fatal("expected", expectedErrStr, "got", err.Error())
 	}
 }
+
+func TestCollectCustomMetricsAppNameSuccess(t *testing.T) {
+	customClient := &customClientTestImpl{}
+
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+		Applications: newrelic.NewApplications(&apmClientTestImpl{}),
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "hax", "*", "hax", "average_response_time", "value"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 1 {
+		t.Fatal("expected", 1, "got", len(ret))
+	}
+
+	if ret[0].Data.(float64) != 100.34 {
+		t.Fatal("expected", 100.34, "got", ret[0].Data.(float64))
+	}
+
+	if ret[0].Tags["app_name"] != "hax" {
+		t.Fatal("expected", "hax", "got", ret[0].Tags["app_name"])
+	}
+
+	if ret[0].Tags["health_status"] != "awesome" {
+		t.Fatal("expected", "awesome", "got", ret[0].Tags["health_status"])
+	}
+
+	if len(customClient.metricDataAppIDs) != 1 {
+		t.Fatal("expected", 1, "got", len(customClient.metricDataAppIDs))
+	}
+
+	if customClient.metricDataAppIDs[0] != 1337 {
+		t.Fatal("expected", 1337, "got", customClient.metricDataAppIDs[0])
+	}
+}
+
+func TestCollectCustomMetricsAppNameWithoutLookupFailure(t *testing.T) {
+	c := &newrelic.Custom{
+		CustomClient: &customClientTestImpl{},
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "hax", "*", "hax", "average_response_time", "value"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		},
+	}
+
+	_, err := c.CollectMetrics(metrics)
+	if err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+
+	expectedErrStr := "Unable to resolve application name: hax"
+	if err.Error() != expectedErrStr {
+		t.Fatal("expected", expectedErrStr, "got", err.Error())
+	}
+}
+
+func TestCollectCustomHostAndInstanceMetricsSuccess(t *testing.T) {
+	customClient := &customClientTestImpl{}
+
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "host", "1337", "42", "5", "Memory/Physical", "total_used_mb", "value"),
+			Tags: map[string]string{
+				"Type": "host",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "instance", "1337", "43", "*", "Memory/Physical", "total_used_mb", "value"),
+			Tags: map[string]string{
+				"Type": "instance",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 2 {
+		t.Fatal("expected", 2, "got", len(ret))
+	}
+
+	if ret[0].Data.(float64) != 512 {
+		t.Fatal("expected", 512, "got", ret[0].Data.(float64))
+	}
+
+	if ret[0].Tags["host_id"] != "42" {
+		t.Fatal("expected", "42", "got", ret[0].Tags["host_id"])
+	}
+
+	if ret[1].Data.(float64) != 256 {
+		t.Fatal("expected", 256, "got", ret[1].Data.(float64))
+	}
+
+	if ret[1].Tags["instance_id"] != "43" {
+		t.Fatal("expected", "43", "got", ret[1].Tags["instance_id"])
+	}
+
+	if len(customClient.metricDataHostIDs) != 1 || customClient.metricDataHostIDs[0] != [2]int{1337, 42} {
+		t.Fatal("expected", [][2]int{{1337, 42}}, "got", customClient.metricDataHostIDs)
+	}
+
+	if len(customClient.metricDataInstanceIDs) != 1 || customClient.metricDataInstanceIDs[0] != [2]int{1337, 43} {
+		t.Fatal("expected", [][2]int{{1337, 43}}, "got", customClient.metricDataInstanceIDs)
+	}
+}
+
+func TestCollectCustomHostMetricsWildcardMetricNameSuccess(t *testing.T) {
+	c := &newrelic.Custom{
+		CustomClient: &customClientTestImpl{},
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "host", "1337", "42", "5", "GC/*", "call_count", "value"),
+			Tags: map[string]string{
+				"Type": "host",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 2 {
+		t.Fatal("expected", 2, "got", len(ret))
+	}
+
+	// Every metric matching the wildcard is reported under its own metric name.
+	expected := []struct {
+		name string
+		data float64
+	}{
+		{"GC/PS Scavenge", 12},
+		{"GC/PS MarkSweep", 3},
+	}
+	for i, e := range expected {
+		if ret[i].Namespace.Element(7).Value != e.name {
+			t.Fatal("expected", e.name, "got", ret[i].Namespace.Element(7).Value)
+		}
+
+		if ret[i].Data.(float64) != e.data {
+			t.Fatal("expected", e.data, "got", ret[i].Data.(float64))
+		}
+	}
+
+	if metrics[0].Namespace.Element(7).Value != "GC/*" {
+		t.Fatal("expected", "GC/*", "got", metrics[0].Namespace.Element(7).Value)
+	}
+}
+
+func TestCollectCustomMobileMetricsSuccess(t *testing.T) {
+	customClient := &customClientTestImpl{}
+
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "mobile", "777", "60", "Mobile/Crash/All", "call_count", "value"),
+			Tags: map[string]string{
+				"Type": "mobile",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 1 {
+		t.Fatal("expected", 1, "got", len(ret))
+	}
+
+	if ret[0].Data.(float64) != 3 {
+		t.Fatal("expected", 3, "got", ret[0].Data.(float64))
+	}
+
+	if ret[0].Tags["mobile_app_id"] != "777" {
+		t.Fatal("expected", "777", "got", ret[0].Tags["mobile_app_id"])
+	}
+
+	if len(customClient.metricDataMobileIDs) != 1 || customClient.metricDataMobileIDs[0] != 777 {
+		t.Fatal("expected", []int{777}, "got", customClient.metricDataMobileIDs)
+	}
+}
+
+func TestCollectCustomMobileMetricsAppNameSuccess(t *testing.T) {
+	customClient := &customClientTestImpl{}
+	mobileClient := &mobileClientTestImpl{}
+
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+		MobileClient: mobileClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "mobile", "shop-ios", "60", "Mobile/Crash/All", "call_count", "value"),
+			Tags: map[string]string{
+				"Type": "mobile",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "mobile", "shop-ios", "30", "Mobile/Crash/All", "call_count", "value"),
+			Tags: map[string]string{
+				"Type": "mobile",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 2 {
+		t.Fatal("expected", 2, "got", len(ret))
+	}
+
+	if ret[0].Tags["mobile_app_id"] != "777" {
+		t.Fatal("expected", "777", "got", ret[0].Tags["mobile_app_id"])
+	}
+
+	if mobileClient.calls != 1 {
+		t.Fatal("expected", 1, "got", mobileClient.calls)
+	}
+
+	metrics[0].Namespace[4].Value = "shop-web"
+	if _, err := c.CollectMetrics(metrics[:1]); err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+}
+
+type batchCustomClientTestImpl struct {
+	customClientTestImpl
+
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (c *batchCustomClientTestImpl) GetApplicationMetricData(appID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.batches = append(c.batches, names)
+
+	// The batches are fetched concurrently, the values are derived from the first name of the batch.
+	batch := 1
+	if names[0] != "External/0/all" {
+		batch = 2
+	}
+
+	resp := &nr.MetricDataResponse{}
+	for i, name := range names {
+		resp.Metrics = append(resp.Metrics, nr.MetricData{
+			Name: name,
+			Timeslices: []nr.MetricTimeslice{
+				{
+					Values: map[string]float64{
+						"call_count": float64(batch*100 + i),
+					},
+				},
+			},
+		})
+	}
+
+	return resp, nil
+}
+
+func TestCollectCustomMetricsBatchedSuccess(t *testing.T) {
+	customClient := &batchCustomClientTestImpl{}
+
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+	}
+
+	metrics := []plugin.Metric{}
+	for i := 0; i < newrelic.MetricDataMaxNames+5; i++ {
+		metrics = append(metrics, plugin.Metric{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "1337", "5", fmt.Sprintf("External/%d/all", i), "call_count", "value"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		})
+	}
+
+	// The same metric name again, it's only requested once.
+	metrics = append(metrics, metrics[0])
+
+	ret, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != len(metrics) {
+		t.Fatal("expected", len(metrics), "got", len(ret))
+	}
+
+	if len(customClient.batches) != 2 {
+		t.Fatal("expected", 2, "got", len(customClient.batches))
+	}
+
+	sort.Slice(customClient.batches, func(i, j int) bool {
+		return len(customClient.batches[i]) > len(customClient.batches[j])
+	})
+
+	if len(customClient.batches[0]) != newrelic.MetricDataMaxNames {
+		t.Fatal("expected", newrelic.MetricDataMaxNames, "got", len(customClient.batches[0]))
+	}
+
+	if len(customClient.batches[1]) != 5 {
+		t.Fatal("expected", 5, "got", len(customClient.batches[1]))
+	}
+
+	expected := []float64{100, 101, 201, 204, 100}
+	for i, idx := range []int{0, 1, newrelic.MetricDataMaxNames + 1, newrelic.MetricDataMaxNames + 4, len(metrics) - 1} {
+		if ret[idx].Data.(float64) != expected[i] {
+			t.Fatal("expected", expected[i], "got", ret[idx].Data.(float64))
+		}
+	}
+}
+
+type seriesCustomClientTestImpl struct {
+	customClientTestImpl
+
+	mu        sync.Mutex
+	summarize []bool
+}
+
+func (c *seriesCustomClientTestImpl) GetApplicationMetricData(appID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	c.mu.Lock()
+	c.summarize = append(c.summarize, options.Summarize)
+	c.mu.Unlock()
+
+	from := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	timeslices := []nr.MetricTimeslice{}
+	for i := 0; i < 3; i++ {
+		timeslices = append(timeslices, nr.MetricTimeslice{
+			From: from.Add(time.Duration(i) * time.Minute),
+			To:   from.Add(time.Duration(i+1) * time.Minute),
+			Values: map[string]float64{
+				"call_count": float64(i + 1),
+			},
+		})
+	}
+
+	if options.Summarize {
+		timeslices = []nr.MetricTimeslice{
+			{
+				From: from,
+				To:   from.Add(3 * time.Minute),
+				Values: map[string]float64{
+					"call_count": 6,
+				},
+			},
+		}
+	}
+
+	return &nr.MetricDataResponse{
+		Metrics: []nr.MetricData{
+			{
+				Name:       names[0],
+				Timeslices: timeslices,
+			},
+		},
+	}, nil
+}
+
+func TestCollectCustomMetricsTimeslicesSuccess(t *testing.T) {
+	customClient := &seriesCustomClientTestImpl{}
+
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "1337", "3", "HttpDispatcher", "call_count", "value"),
+			Config:    plugin.Config{"summarize": false},
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "1337", "3", "HttpDispatcher", "call_count", "value"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 4 {
+		t.Fatal("expected", 4, "got", len(ret))
+	}
+
+	from := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if ret[i].Data.(float64) != float64(i+1) {
+			t.Fatal("expected", float64(i+1), "got", ret[i].Data.(float64))
+		}
+
+		expectedTimestamp := from.Add(time.Duration(i) * time.Minute)
+		if !ret[i].Timestamp.Equal(expectedTimestamp) {
+			t.Fatal("expected", expectedTimestamp, "got", ret[i].Timestamp)
+		}
+	}
+
+	if ret[3].Data.(float64) != 6 {
+		t.Fatal("expected", 6, "got", ret[3].Data.(float64))
+	}
+
+	// The summarized value is reported at the end of the timeframe.
+	expectedTimestamp := from.Add(3 * time.Minute)
+	if !ret[3].Timestamp.Equal(expectedTimestamp) {
+		t.Fatal("expected", expectedTimestamp, "got", ret[3].Timestamp)
+	}
+
+	sort.Slice(customClient.summarize, func(i, j int) bool {
+		return !customClient.summarize[i] && customClient.summarize[j]
+	})
+
+	if fmt.Sprint(customClient.summarize) != fmt.Sprint([]bool{false, true}) {
+		t.Fatal("expected", []bool{false, true}, "got", customClient.summarize)
+	}
+}
+
+func TestCollectCustomMetricsTimeslicesCollectionTimestampSuccess(t *testing.T) {
+	c := &newrelic.Custom{
+		CustomClient: &seriesCustomClientTestImpl{},
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "1337", "3", "HttpDispatcher", "call_count", "value"),
+			Config:    plugin.Config{"summarize": false, "timestamp_source": "collection"},
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 3 {
+		t.Fatal("expected", 3, "got", len(ret))
+	}
+
+	// A series keeps the timestamps of its timeslices even when collection timestamps are configured.
+	from := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := range ret {
+		expectedTimestamp := from.Add(time.Duration(i) * time.Minute)
+		if !ret[i].Timestamp.Equal(expectedTimestamp) {
+			t.Fatal("expected", expectedTimestamp, "got", ret[i].Timestamp)
+		}
+	}
+}
+
+type timeframeCustomClientTestImpl struct {
+	customClientTestImpl
+
+	mu      sync.Mutex
+	options []*nr.MetricDataOptions
+}
+
+func (c *timeframeCustomClientTestImpl) GetApplicationMetricData(appID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	c.mu.Lock()
+	c.options = append(c.options, options)
+	c.mu.Unlock()
+
+	return c.customClientTestImpl.GetApplicationMetricData(appID, names, options)
+}
+
+func TestCollectCustomMetricsAlignedTimeframeSuccess(t *testing.T) {
+	customClient := &timeframeCustomClientTestImpl{}
+
+	clock := time.Date(2017, 1, 1, 12, 47, 30, 0, time.UTC)
+
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+		Clock: func() time.Time {
+			return clock
+		},
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "1337", "60", "hax", "throughput", "value"),
+			Config:    plugin.Config{"align": true, "lag_seconds": int64(120)},
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "1337", "*", "hax", "throughput", "value"),
+			Config:    plugin.Config{"align": true},
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "1337", "*", "hax", "throughput", "value"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		},
+	}
+
+	_, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(customClient.options) != 3 {
+		t.Fatal("expected", 3, "got", len(customClient.options))
+	}
+
+	// The requests are fetched concurrently, order them by timeframe length with the default timeframe last.
+	sort.Slice(customClient.options, func(i, j int) bool {
+		return customClient.options[i].To.Sub(customClient.options[i].From) > customClient.options[j].To.Sub(customClient.options[j].From)
+	})
+
+	// The hour ends at the last whole hour 2 minutes before now, the default 30 minutes at the last half hour.
+	expected := []struct {
+		from time.Time
+		to   time.Time
+	}{
+		{time.Date(2017, 1, 1, 11, 0, 0, 0, time.UTC), time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC), time.Date(2017, 1, 1, 12, 30, 0, 0, time.UTC)},
+	}
+	for i, e := range expected {
+		options := customClient.options[i]
+
+		if !options.From.Equal(e.from) || !options.To.Equal(e.to) {
+			t.Fatal("expected", e.from, e.to, "got", options.From, options.To)
+		}
+	}
+
+	if !customClient.options[2].From.IsZero() || !customClient.options[2].To.IsZero() {
+		t.Fatal("expected", "default timeframe", "got", customClient.options[2].From, customClient.options[2].To)
+	}
+}
+
+func TestCollectCustomMetricsPeriodSuccess(t *testing.T) {
+	customClient := &timeframeCustomClientTestImpl{}
+
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "1337", "1440", "hax", "throughput", "value"),
+			Config:    plugin.Config{"period": int64(3600), "summarize": false},
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", "1337", "1440", "hax", "throughput", "value"),
+			Config:    plugin.Config{"summarize": false},
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		},
+	}
+
+	_, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(customClient.options) != 2 {
+		t.Fatal("expected", 2, "got", len(customClient.options))
+	}
+
+	// The requests are fetched concurrently, order them by period.
+	sort.Slice(customClient.options, func(i, j int) bool {
+		return customClient.options[i].Period > customClient.options[j].Period
+	})
+
+	if customClient.options[0].Period != 3600 {
+		t.Fatal("expected", 3600, "got", customClient.options[0].Period)
+	}
+
+	if customClient.options[0].Summarize {
+		t.Fatal("expected", false, "got", customClient.options[0].Summarize)
+	}
+
+	if customClient.options[1].Period != 0 {
+		t.Fatal("expected", 0, "got", customClient.options[1].Period)
+	}
+}
+
+type parallelCustomClientTestImpl struct {
+	customClientTestImpl
+
+	failEven bool
+
+	running    int
+	maxRunning int
+}
+
+// maxRunningFetches returns the most metric data fetches that ran at the same time.
+func (c *parallelCustomClientTestImpl) maxRunningFetches() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.maxRunning
+}
+
+func (c *parallelCustomClientTestImpl) GetApplicationMetricData(appID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	c.mu.Lock()
+	c.running++
+	if c.running > c.maxRunning {
+		c.maxRunning = c.running
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.running--
+		c.mu.Unlock()
+	}()
+
+	// The first applications respond last.
+	time.Sleep(time.Duration(5-appID) * 5 * time.Millisecond)
+
+	if c.failEven && appID%2 == 0 {
+		return nil, fmt.Errorf("application %d failed", appID)
+	}
+
+	return &nr.MetricDataResponse{
+		Metrics: []nr.MetricData{
+			{
+				Name: names[0],
+				Timeslices: []nr.MetricTimeslice{
+					{
+						Values: map[string]float64{
+							"call_count": float64(appID),
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func TestCollectCustomMetricsParallelSuccess(t *testing.T) {
+	c := &newrelic.Custom{
+		CustomClient: &parallelCustomClientTestImpl{},
+	}
+
+	metrics := []plugin.Metric{}
+	for appID := 1; appID <= 4; appID++ {
+		metrics = append(metrics, plugin.Metric{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", fmt.Sprint(appID), "5", "HttpDispatcher", "call_count", "value"),
+			Config:    plugin.Config{"workers": int64(4)},
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		})
+	}
+
+	ret, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != len(metrics) {
+		t.Fatal("expected", len(metrics), "got", len(ret))
+	}
+
+	// The metrics are returned in the order they're requested, regardless of which fetch finished first.
+	for i, m := range ret {
+		if m.Data.(float64) != float64(i+1) {
+			t.Fatal("expected", float64(i+1), "got", m.Data.(float64))
+		}
+	}
+}
+
+func TestCollectCustomMetricsParallelFailure(t *testing.T) {
+	c := &newrelic.Custom{
+		CustomClient: &parallelCustomClientTestImpl{failEven: true},
+	}
+
+	metrics := []plugin.Metric{}
+	for appID := 1; appID <= 4; appID++ {
+		metrics = append(metrics, plugin.Metric{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", fmt.Sprint(appID), "5", "HttpDispatcher", "call_count", "value"),
+			Config:    plugin.Config{"workers": int64(4)},
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		})
+	}
+
+	_, err := c.CollectMetrics(metrics)
+	if err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+
+	// Application 4 fails first, the error of the first requested failing application is returned.
+	if err.Error() != "application 2 failed" {
+		t.Fatal("expected", "application 2 failed", "got", err.Error())
+	}
+}
+
+func TestCollectCustomMetricsParallelWorkers(t *testing.T) {
+	customClient := &parallelCustomClientTestImpl{}
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+		Workers:      newrelic.NewWorkers(2),
+	}
+
+	metrics := []plugin.Metric{}
+	for appID := 1; appID <= 4; appID++ {
+		metrics = append(metrics, plugin.Metric{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", fmt.Sprint(appID), "5", "HttpDispatcher", "call_count", "value"),
+			Config:    plugin.Config{"workers": int64(4)},
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		})
+	}
+
+	ret, err := c.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != len(metrics) {
+		t.Fatal("expected", len(metrics), "got", len(ret))
+	}
+
+	// The workers of the collection take precedence over the workers config of the metrics.
+	if customClient.maxRunningFetches() > 2 {
+		t.Fatal("expected", 2, "got", customClient.maxRunningFetches())
+	}
+}
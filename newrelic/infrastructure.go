@@ -0,0 +1,760 @@
+package newrelic
+
+import (
+	"fmt"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"strings"
+)
+
+// InfrastructureMinutes is the timeframe, in minutes, the latest infrastructure sample values are queried over.
+const InfrastructureMinutes = 5
+
+// InfrastructureMetrics is a list containing the available infrastructure metrics and their properties.
+var InfrastructureMetrics = []Metric{
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("system"),
+			plugin.NewNamespaceElement("cpuPercent"),
+		},
+		Type: "system",
+		Path: "cpuPercent",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("system"),
+			plugin.NewNamespaceElement("cpuUserPercent"),
+		},
+		Type: "system",
+		Path: "cpuUserPercent",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("system"),
+			plugin.NewNamespaceElement("cpuSystemPercent"),
+		},
+		Type: "system",
+		Path: "cpuSystemPercent",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("system"),
+			plugin.NewNamespaceElement("cpuIOWaitPercent"),
+		},
+		Type: "system",
+		Path: "cpuIOWaitPercent",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("system"),
+			plugin.NewNamespaceElement("loadAverageOneMinute"),
+		},
+		Type: "system",
+		Path: "loadAverageOneMinute",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("system"),
+			plugin.NewNamespaceElement("loadAverageFiveMinute"),
+		},
+		Type: "system",
+		Path: "loadAverageFiveMinute",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("system"),
+			plugin.NewNamespaceElement("loadAverageFifteenMinute"),
+		},
+		Type: "system",
+		Path: "loadAverageFifteenMinute",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("system"),
+			plugin.NewNamespaceElement("memoryUsedBytes"),
+		},
+		Type: "system",
+		Path: "memoryUsedBytes",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("system"),
+			plugin.NewNamespaceElement("memoryFreeBytes"),
+		},
+		Type: "system",
+		Path: "memoryFreeBytes",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("system"),
+			plugin.NewNamespaceElement("memoryTotalBytes"),
+		},
+		Type: "system",
+		Path: "memoryTotalBytes",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("system"),
+			plugin.NewNamespaceElement("diskUsedPercent"),
+		},
+		Type: "system",
+		Path: "diskUsedPercent",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("system"),
+			plugin.NewNamespaceElement("diskUtilizationPercent"),
+		},
+		Type: "system",
+		Path: "diskUtilizationPercent",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("process"),
+			plugin.NamespaceElement{
+				Name:        "process_name",
+				Description: "Process display name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("cpuPercent"),
+		},
+		Type: "process",
+		Path: "cpuPercent",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("process"),
+			plugin.NamespaceElement{
+				Name:        "process_name",
+				Description: "Process display name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("memoryResidentSizeBytes"),
+		},
+		Type: "process",
+		Path: "memoryResidentSizeBytes",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("process"),
+			plugin.NamespaceElement{
+				Name:        "process_name",
+				Description: "Process display name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("memoryVirtualSizeBytes"),
+		},
+		Type: "process",
+		Path: "memoryVirtualSizeBytes",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("process"),
+			plugin.NamespaceElement{
+				Name:        "process_name",
+				Description: "Process display name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("threadCount"),
+		},
+		Type: "process",
+		Path: "threadCount",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("storage"),
+			plugin.NamespaceElement{
+				Name:        "device",
+				Description: "Storage device",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("diskUsedPercent"),
+		},
+		Type: "storage",
+		Path: "diskUsedPercent",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("storage"),
+			plugin.NamespaceElement{
+				Name:        "device",
+				Description: "Storage device",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("diskUsedBytes"),
+		},
+		Type: "storage",
+		Path: "diskUsedBytes",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("storage"),
+			plugin.NamespaceElement{
+				Name:        "device",
+				Description: "Storage device",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("diskFreeBytes"),
+		},
+		Type: "storage",
+		Path: "diskFreeBytes",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("storage"),
+			plugin.NamespaceElement{
+				Name:        "device",
+				Description: "Storage device",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("diskTotalBytes"),
+		},
+		Type: "storage",
+		Path: "diskTotalBytes",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("storage"),
+			plugin.NamespaceElement{
+				Name:        "device",
+				Description: "Storage device",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("totalUtilizationPercent"),
+		},
+		Type: "storage",
+		Path: "totalUtilizationPercent",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("network"),
+			plugin.NamespaceElement{
+				Name:        "interface_name",
+				Description: "Network interface name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("receiveBytesPerSecond"),
+		},
+		Type: "network",
+		Path: "receiveBytesPerSecond",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("network"),
+			plugin.NamespaceElement{
+				Name:        "interface_name",
+				Description: "Network interface name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("transmitBytesPerSecond"),
+		},
+		Type: "network",
+		Path: "transmitBytesPerSecond",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("network"),
+			plugin.NamespaceElement{
+				Name:        "interface_name",
+				Description: "Network interface name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("receiveErrorsPerSecond"),
+		},
+		Type: "network",
+		Path: "receiveErrorsPerSecond",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("host"),
+			plugin.NamespaceElement{
+				Name:        "hostname",
+				Description: "Infrastructure hostname",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("network"),
+			plugin.NamespaceElement{
+				Name:        "interface_name",
+				Description: "Network interface name",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("transmitErrorsPerSecond"),
+		},
+		Type: "network",
+		Path: "transmitErrorsPerSecond",
+		Unit: "float",
+	},
+}
+
+// infrastructureSample describes how an infrastructure metric type maps to an infrastructure agent event type.
+type infrastructureSample struct {
+	EventType string
+	Facet     string
+	Tag       string
+}
+
+// infrastructureSamples maps the infrastructure metric types to their event types. The system samples are only
+// faceted by hostname, the other samples by hostname and the entity they describe.
+var infrastructureSamples = map[string]infrastructureSample{
+	"system": {
+		EventType: "SystemSample",
+	},
+	"process": {
+		EventType: "ProcessSample",
+		Facet:     "processDisplayName",
+		Tag:       "process_name",
+	},
+	"storage": {
+		EventType: "StorageSample",
+		Facet:     "device",
+		Tag:       "device",
+	},
+	"network": {
+		EventType: "NetworkSample",
+		Facet:     "interfaceName",
+		Tag:       "interface_name",
+	},
+}
+
+// InfrastructureSample holds the latest attribute values of a host, or of a process, storage device or network
+// interface on a host.
+type InfrastructureSample struct {
+	Hostname string
+	Entity   string
+	Values   map[string]interface{}
+}
+
+// InfrastructureClient is the interface every infrastructure client needs to implement.
+type InfrastructureClient interface {
+	GetHostnames() ([]string, error)
+	GetSamples(string, []string) ([]InfrastructureSample, error)
+}
+
+// InfrastructureClientImpl is a real implementation of an InfrastructureClient.
+type InfrastructureClientImpl struct {
+	AccountID int
+	QueryKey  string
+}
+
+// GetHostnames fetches the hostnames of all hosts reporting to New Relic Infrastructure.
+func (ic *InfrastructureClientImpl) GetHostnames() ([]string, error) {
+	resp, err := queryInsights(
+		ic.AccountID,
+		ic.QueryKey,
+		fmt.Sprintf("SELECT uniques(hostname) FROM SystemSample SINCE %d minutes ago", InfrastructureMinutes),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	hostnames := []string{}
+	for _, result := range resp.Results {
+		members, ok := result["members"].([]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, member := range members {
+			hostnames = append(hostnames, fmt.Sprint(member))
+		}
+	}
+
+	return hostnames, nil
+}
+
+// GetSamples fetches the latest values of the given attributes of the given infrastructure metric type.
+func (ic *InfrastructureClientImpl) GetSamples(sampleType string, attributes []string) ([]InfrastructureSample, error) {
+	sample, ok := infrastructureSamples[sampleType]
+	if !ok {
+		return nil, fmt.Errorf("Unknown metric type: %s", sampleType)
+	}
+
+	selects := []string{}
+	for _, attribute := range attributes {
+		selects = append(selects, fmt.Sprintf("latest(`%s`)", attribute))
+	}
+
+	facets := "hostname"
+	if sample.Facet != "" {
+		facets += ", " + sample.Facet
+	}
+
+	nrql := fmt.Sprintf(
+		"SELECT %s FROM %s FACET %s SINCE %d minutes ago LIMIT MAX",
+		strings.Join(selects, ", "),
+		sample.EventType,
+		facets,
+		InfrastructureMinutes,
+	)
+
+	resp, err := queryInsights(ic.AccountID, ic.QueryKey, nrql)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := []InfrastructureSample{}
+	for _, facet := range resp.Facets {
+		s := InfrastructureSample{
+			Values: map[string]interface{}{},
+		}
+
+		if names, ok := facet.Name.([]interface{}); ok && len(names) == 2 {
+			s.Hostname = fmt.Sprint(names[0])
+			s.Entity = fmt.Sprint(names[1])
+		} else {
+			s.Hostname = facet.FacetName()
+		}
+
+		for i, result := range facet.Results {
+			if i >= len(attributes) {
+				break
+			}
+
+			value, ok := insightsValue(result)
+			if !ok || value == nil {
+				// No value reported, the metric won't be found and is skipped.
+				continue
+			}
+
+			s.Values[attributes[i]] = value
+		}
+
+		samples = append(samples, s)
+	}
+
+	return samples, nil
+}
+
+// Infrastructure represents the Infrastructure service part of New Relic.
+type Infrastructure struct {
+	InfrastructureClient InfrastructureClient
+	Workers              *Workers
+}
+
+// NewInfrastructure creates and returns a new Infrastructure object with a configured InfrastructureClient and the
+// workers of the collection, if any.
+func NewInfrastructure(accountID int, queryKey string, workers *Workers) Service {
+	return &Infrastructure{
+		InfrastructureClient: &InfrastructureClientImpl{
+			AccountID: accountID,
+			QueryKey:  queryKey,
+		},
+		Workers: workers,
+	}
+}
+
+// GetMetricTypes returns the available infrastructure metric types.
+// When a query key is configured, the metric types are also returned for every host reporting to it.
+func (in *Infrastructure) GetMetricTypes(cfg plugin.Config) ([]plugin.Metric, error) {
+	ns := plugin.NewNamespace("inteleon", "newrelic", "infra")
+
+	metrics, err := metricTypes(ns, InfrastructureMetrics)
+	if err != nil {
+		return metrics, err
+	}
+
+	if _, err := cfg.GetString("query_key"); err != nil {
+		// No query key, no hosts to discover.
+		return metrics, nil
+	}
+
+	hostnames, err := in.InfrastructureClient.GetHostnames()
+	if err != nil {
+		// Hosts not discoverable, e.g. without the account_id config, the generic metric types still work.
+		return metrics, nil
+	}
+
+	for _, hostname := range hostnames {
+		hostMetrics, err := metricTypes(ns, withNamespaceValue(InfrastructureMetrics, "hostname", hostname))
+		if err != nil {
+			return metrics, err
+		}
+
+		for i := range hostMetrics {
+			metrics = append(metrics, hostMetrics[i])
+		}
+	}
+
+	return metrics, nil
+}
+
+// CollectMetrics fetches the requested infrastructure metrics and returns them.
+// Every metric type is fetched with a single query for all requested attributes.
+func (in *Infrastructure) CollectMetrics(metrics []plugin.Metric) ([]plugin.Metric, error) {
+	collectedMetrics := []plugin.Metric{}
+
+	if len(metrics) == 0 {
+		return collectedMetrics, fmt.Errorf("List of metrics is empty")
+	}
+
+	sampleTypes := []string{}
+	attributes := map[string][]string{}
+	for _, m := range metrics {
+		if m.Namespace.Element(2).Value != "infra" {
+			continue
+		}
+
+		sampleType := m.Tags["Type"]
+		if _, ok := infrastructureSamples[sampleType]; !ok {
+			return collectedMetrics, fmt.Errorf("Unknown metric type: %s", sampleType)
+		}
+
+		if _, ok := attributes[sampleType]; !ok {
+			sampleTypes = append(sampleTypes, sampleType)
+		}
+
+		attributes[sampleType] = appendMissing(attributes[sampleType], m.Tags["Path"])
+	}
+
+	// Samples missing, fetching them concurrently per metric type...
+	fetchedSamples := make([][]InfrastructureSample, len(sampleTypes))
+	errs := serviceWorkers(in.Workers, metrics).parallel(len(sampleTypes), func(j int) error {
+		var err error
+		fetchedSamples[j], err = in.InfrastructureClient.GetSamples(sampleTypes[j], attributes[sampleTypes[j]])
+
+		return err
+	})
+
+	if err := firstError(errs); err != nil {
+		return collectedMetrics, err
+	}
+
+	samples := map[string][]InfrastructureSample{}
+	for j, sampleType := range sampleTypes {
+		samples[sampleType] = fetchedSamples[j]
+	}
+
+	for i, m := range metrics {
+		if m.Namespace.Element(2).Value != "infra" {
+			continue
+		}
+
+		sampleType := m.Tags["Type"]
+		hostname := m.Namespace.Element(4).Value
+
+		entity := ""
+		if infrastructureSamples[sampleType].Facet != "" {
+			entity = m.Namespace.Element(6).Value
+		}
+
+		for _, sample := range samples[sampleType] {
+			if hostname != "*" && hostname != sample.Hostname {
+				continue
+			}
+
+			if entity != "" && entity != "*" && entity != sample.Entity {
+				continue
+			}
+
+			sampleMetric := metrics[i]
+			if hostname == "*" {
+				sampleMetric = withElementValue(sampleMetric, 4, sample.Hostname)
+			}
+
+			if entity == "*" {
+				sampleMetric = withElementValue(sampleMetric, 6, sample.Entity)
+			}
+
+			populatedMetric, err := populateMetric(sampleMetric, sample.Values, infrastructureTags(sampleType, sample))
+			if err != nil {
+				// Metric not found, skip reporting it and continue execution.
+				continue
+			}
+
+			collectedMetrics = append(collectedMetrics, populatedMetric)
+		}
+	}
+
+	return collectedMetrics, nil
+}
+
+// infrastructureTags returns the tags describing an infrastructure sample.
+func infrastructureTags(sampleType string, sample InfrastructureSample) map[string]string {
+	tags := map[string]string{
+		"hostname": sample.Hostname,
+	}
+
+	if tag := infrastructureSamples[sampleType].Tag; tag != "" {
+		tags[tag] = sample.Entity
+	}
+
+	return tags
+}
+
+// appendMissing appends the value to the list unless the list already contains it.
+func appendMissing(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+
+	return append(list, value)
+}
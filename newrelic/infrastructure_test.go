@@ -0,0 +1,222 @@
+package newrelic_test
+
+import (
+	"fmt"
+	"github.com/inteleon/snap-plugin-collector-newrelic/newrelic"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type infrastructureClientTestImpl struct {
+	mu sync.Mutex
+
+	sampleTypes []string
+	attributes  map[string][]string
+}
+
+func (ic *infrastructureClientTestImpl) GetHostnames() ([]string, error) {
+	return []string{"web-1", "web-2"}, nil
+}
+
+func (ic *infrastructureClientTestImpl) GetSamples(sampleType string, attributes []string) ([]newrelic.InfrastructureSample, error) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	ic.sampleTypes = append(ic.sampleTypes, sampleType)
+
+	if ic.attributes == nil {
+		ic.attributes = map[string][]string{}
+	}
+
+	ic.attributes[sampleType] = attributes
+
+	switch sampleType {
+	case "system":
+		return []newrelic.InfrastructureSample{
+			{
+				Hostname: "web-1",
+				Values: map[string]interface{}{
+					"cpuPercent":      13.37,
+					"memoryUsedBytes": 1024.0,
+				},
+			},
+			{
+				Hostname: "web-2",
+				Values: map[string]interface{}{
+					"cpuPercent": 12.34,
+				},
+			},
+		}, nil
+	case "storage":
+		return []newrelic.InfrastructureSample{
+			{
+				Hostname: "web-1",
+				Entity:   "/dev/sda1",
+				Values: map[string]interface{}{
+					"diskUsedPercent": 42.0,
+				},
+			},
+			{
+				Hostname: "web-1",
+				Entity:   "/dev/sdb1",
+				Values: map[string]interface{}{
+					"diskUsedPercent": 7.0,
+				},
+			},
+		}, nil
+	}
+
+	return []newrelic.InfrastructureSample{}, nil
+}
+
+func TestGetInfrastructureMetricTypesSuccess(t *testing.T) {
+	in := &newrelic.Infrastructure{}
+
+	metrics, err := in.GetMetricTypes(plugin.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLen := len(newrelic.InfrastructureMetrics)
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	for i, m := range metrics {
+		expectedNS := fmt.Sprintf("inteleon/newrelic/infra/%s", strings.Join(newrelic.InfrastructureMetrics[i].Namespace.Strings(), "/"))
+		ns := strings.Join(m.Namespace.Strings(), "/")
+
+		if ns != expectedNS {
+			t.Fatal("expected", expectedNS, "got", ns)
+		}
+	}
+}
+
+func TestGetInfrastructureMetricTypesWithHosts(t *testing.T) {
+	in := &newrelic.Infrastructure{
+		InfrastructureClient: &infrastructureClientTestImpl{},
+	}
+
+	metrics, err := in.GetMetricTypes(plugin.Config{"query_key": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLen := len(newrelic.InfrastructureMetrics) * 3
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	ns := strings.Join(metrics[len(newrelic.InfrastructureMetrics)].Namespace.Strings(), "/")
+	if ns != "inteleon/newrelic/infra/host/web-1/system/cpuPercent" {
+		t.Fatal("expected", "inteleon/newrelic/infra/host/web-1/system/cpuPercent", "got", ns)
+	}
+}
+
+func TestGetInfrastructureMetricTypesWithoutAccountID(t *testing.T) {
+	// A query key without an account id can't query the hosts.
+	in := newrelic.NewInfrastructure(0, "secret", nil)
+
+	metrics, err := in.GetMetricTypes(plugin.Config{"query_key": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(metrics) != len(newrelic.InfrastructureMetrics) {
+		t.Fatal("expected", len(newrelic.InfrastructureMetrics), "got", len(metrics))
+	}
+}
+
+func TestCollectInfrastructureMetricsSuccess(t *testing.T) {
+	infrastructureClient := &infrastructureClientTestImpl{}
+
+	in := &newrelic.Infrastructure{
+		InfrastructureClient: infrastructureClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "infra", "host", "*", "system", "cpuPercent"),
+			Tags: map[string]string{
+				"Type": "system",
+				"Path": "cpuPercent",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "infra", "host", "web-2", "system", "memoryUsedBytes"),
+			Tags: map[string]string{
+				"Type": "system",
+				"Path": "memoryUsedBytes",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "infra", "host", "web-1", "system", "memoryUsedBytes"),
+			Tags: map[string]string{
+				"Type": "system",
+				"Path": "memoryUsedBytes",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "infra", "host", "web-1", "storage", "*", "diskUsedPercent"),
+			Tags: map[string]string{
+				"Type": "storage",
+				"Path": "diskUsedPercent",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := in.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// web-2 reports no memoryUsedBytes, so it's skipped.
+	if len(ret) != 5 {
+		t.Fatal("expected", 5, "got", len(ret))
+	}
+
+	expected := []struct {
+		ns   string
+		data interface{}
+	}{
+		{"inteleon/newrelic/infra/host/web-1/system/cpuPercent", 13.37},
+		{"inteleon/newrelic/infra/host/web-2/system/cpuPercent", 12.34},
+		{"inteleon/newrelic/infra/host/web-1/system/memoryUsedBytes", 1024.0},
+		{"inteleon/newrelic/infra/host/web-1/storage//dev/sda1/diskUsedPercent", 42.0},
+		{"inteleon/newrelic/infra/host/web-1/storage//dev/sdb1/diskUsedPercent", 7.0},
+	}
+	for i, e := range expected {
+		ns := strings.Join(ret[i].Namespace.Strings(), "/")
+		if ns != e.ns {
+			t.Fatal("expected", e.ns, "got", ns)
+		}
+
+		if ret[i].Data != e.data {
+			t.Fatal("expected", e.data, "got", ret[i].Data)
+		}
+	}
+
+	if ret[4].Tags["device"] != "/dev/sdb1" {
+		t.Fatal("expected", "/dev/sdb1", "got", ret[4].Tags["device"])
+	}
+
+	// The metric types are fetched concurrently.
+	sort.Strings(infrastructureClient.sampleTypes)
+
+	expectedTypes := fmt.Sprint([]string{"storage", "system"})
+	if fmt.Sprint(infrastructureClient.sampleTypes) != expectedTypes {
+		t.Fatal("expected", expectedTypes, "got", infrastructureClient.sampleTypes)
+	}
+
+	expectedAttributes := fmt.Sprint([]string{"cpuPercent", "memoryUsedBytes"})
+	if fmt.Sprint(infrastructureClient.attributes["system"]) != expectedAttributes {
+		t.Fatal("expected", expectedAttributes, "got", infrastructureClient.attributes["system"])
+	}
+}
@@ -0,0 +1,76 @@
+package newrelic
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// InsightsBaseURL is the base URL of the New Relic Insights query API.
+const InsightsBaseURL = "https://insights-api.newrelic.com/v1/accounts/"
+
+// InsightsFacet is a single facet of a faceted NRQL query result.
+type InsightsFacet struct {
+	Name    interface{}              `json:"name"`
+	Results []map[string]interface{} `json:"results"`
+}
+
+// FacetName returns the name of the facet. The values of a facet on multiple attributes are joined by a comma.
+func (f InsightsFacet) FacetName() string {
+	values, ok := f.Name.([]interface{})
+	if !ok {
+		return fmt.Sprint(f.Name)
+	}
+
+	names := []string{}
+	for _, v := range values {
+		names = append(names, fmt.Sprint(v))
+	}
+
+	return strings.Join(names, ",")
+}
+
+// InsightsResponse is the result of an NRQL query.
+type InsightsResponse struct {
+	Results []map[string]interface{} `json:"results"`
+	Facets  []InsightsFacet          `json:"facets"`
+}
+
+// queryInsights runs an NRQL query against the Insights query API of the given account.
+func queryInsights(accountID int, queryKey string, nrql string) (*InsightsResponse, error) {
+	if accountID == 0 || queryKey == "" {
+		return nil, fmt.Errorf("NRQL queries require the account_id and query_key configuration")
+	}
+
+	r := &restClient{
+		Key:        queryKey,
+		KeyHeader:  "X-Query-Key",
+		BaseURL:    fmt.Sprintf("%s%d/", InsightsBaseURL, accountID),
+		HTTPClient: httpClient,
+	}
+
+	resp := &InsightsResponse{}
+	if err := r.get("query", url.Values{"nrql": {nrql}}, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// insightsValue returns the value of a single NRQL function result, e.g. {"latest": 13.37}. Nested results holding a
+// single value, e.g. {"percentiles": {"95": 13.37}}, are unwrapped.
+func insightsValue(result map[string]interface{}) (interface{}, bool) {
+	if len(result) != 1 {
+		return nil, false
+	}
+
+	for _, v := range result {
+		if nested, ok := v.(map[string]interface{}); ok {
+			return insightsValue(nested)
+		}
+
+		return v, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,311 @@
+package newrelic
+
+import (
+	"fmt"
+	"github.com/fatih/structs"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	nr "github.com/yfronto/newrelic"
+	"strconv"
+)
+
+// KeyTransactionMetrics is a list containing the available key transaction metrics and their properties.
+var KeyTransactionMetrics = []Metric{
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "key_transaction_id",
+				Description: "Key transaction id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("health"),
+			plugin.NewNamespaceElement("status"),
+		},
+		Type: "key_transaction",
+		Path: "HealthStatus",
+		Unit: "string",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "key_transaction_id",
+				Description: "Key transaction id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("reporting"),
+		},
+		Type: "key_transaction",
+		Path: "Reporting",
+		Unit: "bool",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "key_transaction_id",
+				Description: "Key transaction id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("application"),
+			plugin.NewNamespaceElement("response_time"),
+		},
+		Type: "key_transaction",
+		Path: "ApplicationSummary/ResponseTime",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "key_transaction_id",
+				Description: "Key transaction id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("application"),
+			plugin.NewNamespaceElement("throughput"),
+		},
+		Type: "key_transaction",
+		Path: "ApplicationSummary/Throughput",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "key_transaction_id",
+				Description: "Key transaction id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("application"),
+			plugin.NewNamespaceElement("error_rate"),
+		},
+		Type: "key_transaction",
+		Path: "ApplicationSummary/ErrorRate",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "key_transaction_id",
+				Description: "Key transaction id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("application"),
+			plugin.NewNamespaceElement("apdex_target"),
+		},
+		Type: "key_transaction",
+		Path: "ApplicationSummary/ApdexTarget",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "key_transaction_id",
+				Description: "Key transaction id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("application"),
+			plugin.NewNamespaceElement("apdex_score"),
+		},
+		Type: "key_transaction",
+		Path: "ApplicationSummary/ApdexScore",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "key_transaction_id",
+				Description: "Key transaction id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("user"),
+			plugin.NewNamespaceElement("response_time"),
+		},
+		Type: "key_transaction",
+		Path: "EndUserSummary/ResponseTime",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "key_transaction_id",
+				Description: "Key transaction id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("user"),
+			plugin.NewNamespaceElement("throughput"),
+		},
+		Type: "key_transaction",
+		Path: "EndUserSummary/Throughput",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "key_transaction_id",
+				Description: "Key transaction id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("user"),
+			plugin.NewNamespaceElement("apdex_target"),
+		},
+		Type: "key_transaction",
+		Path: "EndUserSummary/ApdexTarget",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "key_transaction_id",
+				Description: "Key transaction id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("user"),
+			plugin.NewNamespaceElement("apdex_score"),
+		},
+		Type: "key_transaction",
+		Path: "EndUserSummary/ApdexScore",
+		Unit: "float",
+	},
+}
+
+// KeyTransactionClient is the interface every key transaction client needs to implement.
+type KeyTransactionClient interface {
+	GetKeyTransactions() ([]nr.KeyTransaction, error)
+}
+
+// KeyTransactionClientImpl is a real implementation of a KeyTransactionClient.
+type KeyTransactionClientImpl struct {
+	APIKey string
+}
+
+// GetKeyTransactions fetches all key transactions from New Relic.
+func (k *KeyTransactionClientImpl) GetKeyTransactions() ([]nr.KeyTransaction, error) {
+	c := newNRClient(k.APIKey)
+
+	return c.GetKeyTransactions(&nr.KeyTransactionsOptions{})
+}
+
+// KeyTransactions represents the key transactions part of New Relic.
+type KeyTransactions struct {
+	KeyTransactionClient KeyTransactionClient
+}
+
+// NewKeyTransactions creates and returns a new KeyTransactions object with a configured KeyTransactionClient.
+func NewKeyTransactions(apiKey string) Service {
+	return &KeyTransactions{
+		KeyTransactionClient: &KeyTransactionClientImpl{
+			APIKey: apiKey,
+		},
+	}
+}
+
+// GetMetricTypes returns the available key transaction metric types.
+// When an API key is configured, the metric types are also returned for every key transaction visible to it.
+func (k *KeyTransactions) GetMetricTypes(cfg plugin.Config) ([]plugin.Metric, error) {
+	ns := plugin.NewNamespace("inteleon", "newrelic", "key_transaction")
+
+	metrics, err := metricTypes(ns, KeyTransactionMetrics)
+	if err != nil {
+		return metrics, err
+	}
+
+	if _, err := cfg.GetString("api_key"); err != nil {
+		// No API key, no key transactions to discover.
+		return metrics, nil
+	}
+
+	keyTransactions, err := k.KeyTransactionClient.GetKeyTransactions()
+	if err != nil {
+		// Key transactions not discoverable, the generic metric types still work.
+		return metrics, nil
+	}
+
+	for _, kt := range keyTransactions {
+		ktMetrics, err := metricTypes(ns, withNamespaceValue(KeyTransactionMetrics, "key_transaction_id", strconv.Itoa(kt.ID)))
+		if err != nil {
+			return metrics, err
+		}
+
+		for i := range ktMetrics {
+			metrics = append(metrics, ktMetrics[i])
+		}
+	}
+
+	return metrics, nil
+}
+
+// CollectMetrics fetches the requested key transaction metrics and returns them.
+func (k *KeyTransactions) CollectMetrics(metrics []plugin.Metric) ([]plugin.Metric, error) {
+	collectedMetrics := []plugin.Metric{}
+
+	if len(metrics) == 0 {
+		return collectedMetrics, fmt.Errorf("List of metrics is empty")
+	}
+
+	var keyTransactions []nr.KeyTransaction
+	for i, m := range metrics {
+		if m.Namespace.Element(2).Value != "key_transaction" {
+			continue
+		}
+
+		if keyTransactions == nil {
+			// Key transactions missing, fetching...
+			fetchedKeyTransactions, err := k.KeyTransactionClient.GetKeyTransactions()
+			if err != nil {
+				return collectedMetrics, err
+			}
+
+			keyTransactions = fetchedKeyTransactions
+		}
+
+		ktID := m.Namespace.Element(3).Value
+		for _, kt := range keyTransactions {
+			if ktID != "*" && ktID != strconv.Itoa(kt.ID) && ktID != kt.Name {
+				continue
+			}
+
+			ktMetric := metrics[i]
+			if ktID == "*" {
+				ktMetric = withElementValue(ktMetric, 3, strconv.Itoa(kt.ID))
+			}
+
+			populatedMetric, err := populateMetric(ktMetric, structs.Map(kt), keyTransactionTags(kt))
+			if err != nil {
+				// Metric not found, skip reporting it and continue execution.
+				continue
+			}
+
+			collectedMetrics = append(collectedMetrics, withSourceTimestamp(populatedMetric, kt.LastReportedAt))
+		}
+	}
+
+	return collectedMetrics, nil
+}
+
+// keyTransactionTags returns the tags describing a key transaction.
+func keyTransactionTags(kt nr.KeyTransaction) map[string]string {
+	return map[string]string{
+		"key_transaction_id":   strconv.Itoa(kt.ID),
+		"key_transaction_name": kt.Name,
+		"transaction_name":     kt.TransactionName,
+		"health_status":        kt.HealthStatus,
+		"app_id":               strconv.Itoa(kt.Links.Application),
+	}
+}
@@ -0,0 +1,162 @@
+package newrelic_test
+
+import (
+	"fmt"
+	"github.com/inteleon/snap-plugin-collector-newrelic/newrelic"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	nr "github.com/yfronto/newrelic"
+	"strings"
+	"testing"
+)
+
+type keyTransactionClientTestImpl struct {
+	calls int
+}
+
+func (k *keyTransactionClientTestImpl) GetKeyTransactions() ([]nr.KeyTransaction, error) {
+	k.calls++
+
+	return []nr.KeyTransaction{
+		{
+			ID:              42,
+			Name:            "checkout",
+			TransactionName: "WebTransaction/Go/checkout",
+			HealthStatus:    "green",
+			ApplicationSummary: nr.ApplicationSummary{
+				ResponseTime: 120.5,
+			},
+			EndUserSummary: nr.EndUserSummary{
+				ApdexScore: 0.95,
+			},
+			Links: nr.KeyTransactionLinks{
+				Application: 1337,
+			},
+		},
+		{
+			ID:           43,
+			Name:         "login",
+			HealthStatus: "red",
+			ApplicationSummary: nr.ApplicationSummary{
+				ResponseTime: 80.25,
+			},
+		},
+	}, nil
+}
+
+func TestGetKeyTransactionMetricTypesSuccess(t *testing.T) {
+	k := &newrelic.KeyTransactions{}
+
+	metrics, err := k.GetMetricTypes(plugin.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLen := len(newrelic.KeyTransactionMetrics)
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	for i, m := range metrics {
+		expectedNS := fmt.Sprintf("inteleon/newrelic/key_transaction/%s", strings.Join(newrelic.KeyTransactionMetrics[i].Namespace.Strings(), "/"))
+		ns := strings.Join(m.Namespace.Strings(), "/")
+
+		if ns != expectedNS {
+			t.Fatal("expected", expectedNS, "got", ns)
+		}
+	}
+}
+
+func TestGetKeyTransactionMetricTypesDiscoverySuccess(t *testing.T) {
+	k := &newrelic.KeyTransactions{
+		KeyTransactionClient: &keyTransactionClientTestImpl{},
+	}
+
+	metrics, err := k.GetMetricTypes(plugin.Config{"api_key": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLen := len(newrelic.KeyTransactionMetrics) * 3
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	ktID := metrics[len(newrelic.KeyTransactionMetrics)].Namespace.Element(3).Value
+	if ktID != "42" {
+		t.Fatal("expected", "42", "got", ktID)
+	}
+}
+
+func TestCollectKeyTransactionMetricsSuccess(t *testing.T) {
+	ktClient := &keyTransactionClientTestImpl{}
+
+	k := &newrelic.KeyTransactions{
+		KeyTransactionClient: ktClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "key_transaction", "*", "show", "summary", "application", "response_time"),
+			Tags: map[string]string{
+				"Type": "key_transaction",
+				"Path": "ApplicationSummary/ResponseTime",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "key_transaction", "checkout", "show", "summary", "user", "apdex_score"),
+			Tags: map[string]string{
+				"Type": "key_transaction",
+				"Path": "EndUserSummary/ApdexScore",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "1337", "show", "reporting"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Path": "Reporting",
+				"Unit": "bool",
+			},
+		},
+	}
+
+	ret, err := k.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 3 {
+		t.Fatal("expected", 3, "got", len(ret))
+	}
+
+	expected := []struct {
+		ktID string
+		data float64
+	}{
+		{"42", 120.5},
+		{"43", 80.25},
+		{"checkout", 0.95},
+	}
+	for i, e := range expected {
+		if ret[i].Namespace.Element(3).Value != e.ktID {
+			t.Fatal("expected", e.ktID, "got", ret[i].Namespace.Element(3).Value)
+		}
+
+		if ret[i].Data.(float64) != e.data {
+			t.Fatal("expected", e.data, "got", ret[i].Data.(float64))
+		}
+	}
+
+	if ret[0].Tags["app_id"] != "1337" {
+		t.Fatal("expected", "1337", "got", ret[0].Tags["app_id"])
+	}
+
+	if ret[1].Tags["health_status"] != "red" {
+		t.Fatal("expected", "red", "got", ret[1].Tags["health_status"])
+	}
+
+	if ktClient.calls != 1 {
+		t.Fatal("expected", 1, "got", ktClient.calls)
+	}
+}
@@ -0,0 +1,360 @@
+package newrelic
+
+import (
+	"fmt"
+	"github.com/fatih/structs"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"net/url"
+	"strconv"
+)
+
+// MobileMetrics is a list containing the available mobile application metrics and their properties.
+var MobileMetrics = []Metric{
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Mobile application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("health"),
+			plugin.NewNamespaceElement("status"),
+		},
+		Type: "mobile",
+		Path: "HealthStatus",
+		Unit: "string",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Mobile application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("reporting"),
+		},
+		Type: "mobile",
+		Path: "Reporting",
+		Unit: "bool",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Mobile application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("active_users"),
+		},
+		Type: "mobile",
+		Path: "MobileSummary/ActiveUsers",
+		Unit: "int",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Mobile application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("launch_count"),
+		},
+		Type: "mobile",
+		Path: "MobileSummary/LaunchCount",
+		Unit: "int",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Mobile application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("throughput"),
+		},
+		Type: "mobile",
+		Path: "MobileSummary/Throughput",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Mobile application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("response_time"),
+		},
+		Type: "mobile",
+		Path: "MobileSummary/ResponseTime",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Mobile application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("interaction_time"),
+		},
+		Type: "mobile",
+		Path: "MobileSummary/InteractionTime",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Mobile application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("http_error_rate"),
+		},
+		Type: "mobile",
+		Path: "MobileSummary/RemoteErrorRate",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Mobile application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("network_failure_rate"),
+		},
+		Type: "mobile",
+		Path: "MobileSummary/FailedCallRate",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Mobile application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("crash_count"),
+		},
+		Type: "mobile",
+		Path: "CrashSummary/CrashCount",
+		Unit: "int",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Mobile application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("crash_rate"),
+		},
+		Type: "mobile",
+		Path: "CrashSummary/CrashRate",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("application"),
+			plugin.NamespaceElement{
+				Name:        "app_id",
+				Description: "Mobile application id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("show"),
+			plugin.NewNamespaceElement("summary"),
+			plugin.NewNamespaceElement("unresolved_crash_count"),
+		},
+		Type: "mobile",
+		Path: "CrashSummary/UnresolvedCrashCount",
+		Unit: "int",
+	},
+}
+
+// MobileSummary is the usage and performance summary of a mobile application.
+type MobileSummary struct {
+	ActiveUsers     int     `json:"active_users"`
+	LaunchCount     int     `json:"launch_count"`
+	Throughput      float64 `json:"throughput"`
+	ResponseTime    float64 `json:"response_time"`
+	CallsPerSession float64 `json:"calls_per_session"`
+	InteractionTime float64 `json:"interaction_time"`
+	FailedCallRate  float64 `json:"failed_call_rate"`
+	RemoteErrorRate float64 `json:"remote_error_rate"`
+}
+
+// MobileCrashSummary is the crash summary of a mobile application.
+type MobileCrashSummary struct {
+	SupportsCrashData    bool    `json:"supports_crash_data"`
+	UnresolvedCrashCount int     `json:"unresolved_crash_count"`
+	CrashCount           int     `json:"crash_count"`
+	CrashRate            float64 `json:"crash_rate"`
+}
+
+// MobileApplication is a mobile application reporting to New Relic.
+type MobileApplication struct {
+	ID            int                `json:"id"`
+	Name          string             `json:"name"`
+	HealthStatus  string             `json:"health_status"`
+	Reporting     bool               `json:"reporting"`
+	MobileSummary MobileSummary      `json:"mobile_summary"`
+	CrashSummary  MobileCrashSummary `json:"crash_summary"`
+}
+
+// MobileClient is the interface every mobile client needs to implement.
+type MobileClient interface {
+	GetMobileApplications() ([]MobileApplication, error)
+}
+
+// MobileClientImpl is a real implementation of a MobileClient.
+type MobileClientImpl struct {
+	APIKey string
+}
+
+// GetMobileApplications fetches all mobile applications from New Relic.
+func (mc *MobileClientImpl) GetMobileApplications() ([]MobileApplication, error) {
+	r := newRESTClient(mc.APIKey)
+
+	resp := struct {
+		Applications []MobileApplication `json:"applications"`
+	}{}
+
+	err := r.get("mobile_applications.json", url.Values{}, &resp)
+
+	return resp.Applications, err
+}
+
+// Mobile represents the mobile service part of New Relic.
+type Mobile struct {
+	MobileClient MobileClient
+}
+
+// NewMobile creates and returns a new Mobile object with a configured MobileClient.
+func NewMobile(apiKey string) Service {
+	return &Mobile{
+		MobileClient: &MobileClientImpl{
+			APIKey: apiKey,
+		},
+	}
+}
+
+// GetMetricTypes returns the available mobile metric types.
+// When an API key is configured, the metric types are also returned for every mobile application visible to it.
+func (mo *Mobile) GetMetricTypes(cfg plugin.Config) ([]plugin.Metric, error) {
+	ns := plugin.NewNamespace("inteleon", "newrelic", "mobile")
+
+	metrics, err := metricTypes(ns, MobileMetrics)
+	if err != nil {
+		return metrics, err
+	}
+
+	if _, err := cfg.GetString("api_key"); err != nil {
+		// No API key, no mobile applications to discover.
+		return metrics, nil
+	}
+
+	apps, err := mo.MobileClient.GetMobileApplications()
+	if err != nil {
+		// Mobile applications not discoverable, the generic metric types still work.
+		return metrics, nil
+	}
+
+	for _, app := range apps {
+		appMetrics, err := metricTypes(ns, withNamespaceValue(MobileMetrics, "app_id", strconv.Itoa(app.ID)))
+		if err != nil {
+			return metrics, err
+		}
+
+		for i := range appMetrics {
+			metrics = append(metrics, appMetrics[i])
+		}
+	}
+
+	return metrics, nil
+}
+
+// CollectMetrics fetches the requested mobile metrics and returns them.
+func (mo *Mobile) CollectMetrics(metrics []plugin.Metric) ([]plugin.Metric, error) {
+	collectedMetrics := []plugin.Metric{}
+
+	if len(metrics) == 0 {
+		return collectedMetrics, fmt.Errorf("List of metrics is empty")
+	}
+
+	var apps []MobileApplication
+	for i, m := range metrics {
+		if m.Namespace.Element(2).Value != "mobile" {
+			continue
+		}
+
+		if apps == nil {
+			// Mobile applications missing, fetching...
+			fetchedApps, err := mo.MobileClient.GetMobileApplications()
+			if err != nil {
+				return collectedMetrics, err
+			}
+
+			apps = fetchedApps
+		}
+
+		appID := m.Namespace.Element(4).Value
+		for _, app := range apps {
+			if appID != "*" && appID != strconv.Itoa(app.ID) && appID != app.Name {
+				continue
+			}
+
+			appMetric := metrics[i]
+			if appID == "*" {
+				appMetric = withElementValue(appMetric, 4, strconv.Itoa(app.ID))
+			}
+
+			tags := map[string]string{
+				"app_id":        strconv.Itoa(app.ID),
+				"app_name":      app.Name,
+				"health_status": app.HealthStatus,
+			}
+
+			populatedMetric, err := populateMetric(appMetric, structs.Map(app), tags)
+			if err != nil {
+				// Metric not found, skip reporting it and continue execution.
+				continue
+			}
+
+			collectedMetrics = append(collectedMetrics, populatedMetric)
+		}
+	}
+
+	return collectedMetrics, nil
+}
@@ -0,0 +1,134 @@
+package newrelic_test
+
+import (
+	"fmt"
+	"github.com/inteleon/snap-plugin-collector-newrelic/newrelic"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"strings"
+	"testing"
+)
+
+type mobileClientTestImpl struct {
+	calls int
+}
+
+func (mc *mobileClientTestImpl) GetMobileApplications() ([]newrelic.MobileApplication, error) {
+	mc.calls++
+
+	return []newrelic.MobileApplication{
+		{
+			ID:           777,
+			Name:         "shop-ios",
+			HealthStatus: "green",
+			MobileSummary: newrelic.MobileSummary{
+				ActiveUsers:     1200,
+				RemoteErrorRate: 0.5,
+			},
+			CrashSummary: newrelic.MobileCrashSummary{
+				CrashRate: 0.01,
+			},
+		},
+		{
+			ID:           778,
+			Name:         "shop-android",
+			HealthStatus: "orange",
+			MobileSummary: newrelic.MobileSummary{
+				ActiveUsers: 3400,
+			},
+		},
+	}, nil
+}
+
+func TestGetMobileMetricTypesSuccess(t *testing.T) {
+	mo := &newrelic.Mobile{}
+
+	metrics, err := mo.GetMetricTypes(plugin.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLen := len(newrelic.MobileMetrics)
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	for i, m := range metrics {
+		expectedNS := fmt.Sprintf("inteleon/newrelic/mobile/%s", strings.Join(newrelic.MobileMetrics[i].Namespace.Strings(), "/"))
+		ns := strings.Join(m.Namespace.Strings(), "/")
+
+		if ns != expectedNS {
+			t.Fatal("expected", expectedNS, "got", ns)
+		}
+	}
+}
+
+func TestCollectMobileMetricsSuccess(t *testing.T) {
+	mobileClient := &mobileClientTestImpl{}
+
+	mo := &newrelic.Mobile{
+		MobileClient: mobileClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "mobile", "application", "*", "show", "summary", "active_users"),
+			Tags: map[string]string{
+				"Type": "mobile",
+				"Path": "MobileSummary/ActiveUsers",
+				"Unit": "int",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "mobile", "application", "shop-ios", "show", "summary", "crash_rate"),
+			Tags: map[string]string{
+				"Type": "mobile",
+				"Path": "CrashSummary/CrashRate",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "mobile", "application", "777", "show", "summary", "http_error_rate"),
+			Tags: map[string]string{
+				"Type": "mobile",
+				"Path": "MobileSummary/RemoteErrorRate",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := mo.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 4 {
+		t.Fatal("expected", 4, "got", len(ret))
+	}
+
+	expected := []struct {
+		appID string
+		data  interface{}
+	}{
+		{"777", 1200},
+		{"778", 3400},
+		{"shop-ios", 0.01},
+		{"777", 0.5},
+	}
+	for i, e := range expected {
+		if ret[i].Namespace.Element(4).Value != e.appID {
+			t.Fatal("expected", e.appID, "got", ret[i].Namespace.Element(4).Value)
+		}
+
+		if ret[i].Data != e.data {
+			t.Fatal("expected", e.data, "got", ret[i].Data)
+		}
+	}
+
+	if ret[1].Tags["health_status"] != "orange" {
+		t.Fatal("expected", "orange", "got", ret[1].Tags["health_status"])
+	}
+
+	if mobileClient.calls != 1 {
+		t.Fatal("expected", 1, "got", mobileClient.calls)
+	}
+}
@@ -0,0 +1,443 @@
+package newrelic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	nr "github.com/yfronto/newrelic"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NerdGraphURL is the URL of the New Relic NerdGraph (GraphQL) API.
+const NerdGraphURL = "https://api.newrelic.com/graphql"
+
+// nerdGraphApplicationsSearch is the entity search matching all APM applications.
+const nerdGraphApplicationsSearch = "domain = 'APM' AND type = 'APPLICATION'"
+
+// nerdGraphApplicationsQuery lists the APM applications matching the entity search, one page per cursor.
+const nerdGraphApplicationsQuery = `query($query: String!, $cursor: String) {
+  actor {
+    entitySearch(query: $query) {
+      results(cursor: $cursor) {
+        nextCursor
+        entities {
+          ... on ApmApplicationEntityOutline {
+            applicationId
+            name
+            language
+            reporting
+            alertSeverity
+            apmSummary {
+              apdexScore
+              errorRate
+              hostCount
+              instanceCount
+              responseTimeAverage
+              throughput
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// nerdGraphNRQLQuery runs an NRQL query against an account.
+const nerdGraphNRQLQuery = `query($accountId: Int!, $nrql: Nrql!) {
+  actor {
+    account(id: $accountId) {
+      nrql(query: $nrql) {
+        results
+      }
+    }
+  }
+}`
+
+// nerdGraphMetricValues maps the metric data value names of the REST API to the NRQL functions returning them from
+// the metric timeslice data. Response and call times are reported in seconds and converted to milliseconds.
+var nerdGraphMetricValues = []struct {
+	Name     string
+	Function string
+}{
+	{"call_count", "count(newrelic.timeslice.value)"},
+	{"calls_per_minute", "rate(count(newrelic.timeslice.value), 1 minute)"},
+	{"average_response_time", "average(newrelic.timeslice.value) * 1000"},
+	{"min_response_time", "min(newrelic.timeslice.value) * 1000"},
+	{"max_response_time", "max(newrelic.timeslice.value) * 1000"},
+	{"total_call_time", "sum(newrelic.timeslice.value) * 1000"},
+	{"average_value", "average(newrelic.timeslice.value)"},
+	{"min_value", "min(newrelic.timeslice.value)"},
+	{"max_value", "max(newrelic.timeslice.value)"},
+	{"total_value", "sum(newrelic.timeslice.value)"},
+}
+
+// nerdGraphHealthStatus maps the NerdGraph alert severities to the health statuses of the REST API.
+var nerdGraphHealthStatus = map[string]string{
+	"NOT_ALERTING":   "green",
+	"WARNING":        "orange",
+	"CRITICAL":       "red",
+	"NOT_CONFIGURED": "gray",
+}
+
+// nerdGraphUnsupportedApplicationPaths are the application fields NerdGraph doesn't supply.
+var nerdGraphUnsupportedApplicationPaths = []string{
+	"ApplicationSummary/ApdexTarget",
+	"ApplicationSummary/ConcurrentInstanceCount",
+	"EndUserSummary",
+}
+
+// nerdGraphClient talks to the New Relic NerdGraph API.
+type nerdGraphClient struct {
+	Key        string
+	URL        string
+	HTTPClient *http.Client
+}
+
+func newNerdGraphClient(apiKey string, url string) *nerdGraphClient {
+	if url == "" {
+		url = NerdGraphURL
+	}
+
+	return &nerdGraphClient{
+		Key:        apiKey,
+		URL:        url,
+		HTTPClient: httpClient,
+	}
+}
+
+// query runs the GraphQL query and decodes the data of the response into out.
+func (g *nerdGraphClient) query(query string, variables map[string]interface{}, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", g.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("API-Key", g.Key)
+
+	resp, err := g.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+
+		return fmt.Errorf("New Relic API request failed with status %d: %s", resp.StatusCode, respBody)
+	}
+
+	graphQLResp := struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&graphQLResp); err != nil {
+		return err
+	}
+
+	if len(graphQLResp.Errors) > 0 {
+		return fmt.Errorf("NerdGraph request failed: %s", graphQLResp.Errors[0].Message)
+	}
+
+	return json.Unmarshal(graphQLResp.Data, out)
+}
+
+// nrql runs the NRQL query against the account and returns the result rows.
+func (g *nerdGraphClient) nrql(accountID int, nrql string) ([]map[string]interface{}, error) {
+	if accountID == 0 {
+		return nil, fmt.Errorf("NRQL queries require the account_id configuration")
+	}
+
+	resp := struct {
+		Actor struct {
+			Account struct {
+				NRQL struct {
+					Results []map[string]interface{} `json:"results"`
+				} `json:"nrql"`
+			} `json:"account"`
+		} `json:"actor"`
+	}{}
+
+	err := g.query(
+		nerdGraphNRQLQuery,
+		map[string]interface{}{
+			"accountId": accountID,
+			"nrql":      nrql,
+		},
+		&resp,
+	)
+
+	return resp.Actor.Account.NRQL.Results, err
+}
+
+// nerdGraphUnsupported returns the error reported for the data the NerdGraph backend can't fetch.
+func nerdGraphUnsupported(what string) error {
+	return fmt.Errorf("%s are not supported by the nerdgraph backend", what)
+}
+
+// NerdGraphAPMClient is an implementation of an APMClient using the NerdGraph API. The URL defaults to NerdGraphURL.
+type NerdGraphAPMClient struct {
+	APIKey string
+	URL    string
+}
+
+// GetApplication fetches application information from New Relic (APM).
+// The application id of an APM application is the domain id of its entity.
+func (a *NerdGraphAPMClient) GetApplication(appID int) (*nr.Application, error) {
+	apps, err := a.searchApplications(fmt.Sprintf("%s AND domainId = '%d'", nerdGraphApplicationsSearch, appID))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range apps {
+		if apps[i].ID == appID {
+			return &apps[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("Application not found: %d", appID)
+}
+
+// GetApplications fetches all applications visible to the API key from New Relic (APM).
+func (a *NerdGraphAPMClient) GetApplications() ([]nr.Application, error) {
+	return a.searchApplications(nerdGraphApplicationsSearch)
+}
+
+// searchApplications fetches the applications matching the entity search. The summary values are converted to the
+// units of the REST API.
+func (a *NerdGraphAPMClient) searchApplications(query string) ([]nr.Application, error) {
+	g := newNerdGraphClient(a.APIKey, a.URL)
+
+	apps := []nr.Application{}
+	cursor := ""
+	for p := 1; p <= RESTMaxPages; p++ {
+		resp := struct {
+			Actor struct {
+				EntitySearch struct {
+					Results struct {
+						NextCursor string `json:"nextCursor"`
+						Entities   []struct {
+							ApplicationID int    `json:"applicationId"`
+							Name          string `json:"name"`
+							Language      string `json:"language"`
+							Reporting     bool   `json:"reporting"`
+							AlertSeverity string `json:"alertSeverity"`
+							APMSummary    struct {
+								ApdexScore          float64 `json:"apdexScore"`
+								ErrorRate           float64 `json:"errorRate"`
+								HostCount           int     `json:"hostCount"`
+								InstanceCount       int     `json:"instanceCount"`
+								ResponseTimeAverage float64 `json:"responseTimeAverage"`
+								Throughput          float64 `json:"throughput"`
+							} `json:"apmSummary"`
+						} `json:"entities"`
+					} `json:"results"`
+				} `json:"entitySearch"`
+			} `json:"actor"`
+		}{}
+
+		variables := map[string]interface{}{
+			"query": query,
+		}
+		if cursor != "" {
+			variables["cursor"] = cursor
+		}
+
+		if err := g.query(nerdGraphApplicationsQuery, variables, &resp); err != nil {
+			return nil, err
+		}
+
+		results := resp.Actor.EntitySearch.Results
+		for _, entity := range results.Entities {
+			healthStatus, ok := nerdGraphHealthStatus[entity.AlertSeverity]
+			if !ok {
+				healthStatus = "unknown"
+			}
+
+			apps = append(apps, nr.Application{
+				ID:           entity.ApplicationID,
+				Name:         entity.Name,
+				Language:     entity.Language,
+				HealthStatus: healthStatus,
+				Reporting:    entity.Reporting,
+				ApplicationSummary: nr.ApplicationSummary{
+					ResponseTime:  entity.APMSummary.ResponseTimeAverage * 1000,
+					Throughput:    entity.APMSummary.Throughput,
+					ErrorRate:     entity.APMSummary.ErrorRate * 100,
+					ApdexScore:    entity.APMSummary.ApdexScore,
+					HostCount:     entity.APMSummary.HostCount,
+					InstanceCount: entity.APMSummary.InstanceCount,
+				},
+			})
+		}
+
+		if results.NextCursor == "" {
+			break
+		}
+
+		cursor = results.NextCursor
+	}
+
+	return apps, nil
+}
+
+// UnsupportedApplicationPaths returns the application fields NerdGraph doesn't supply, so they aren't reported as
+// zeros.
+func (a *NerdGraphAPMClient) UnsupportedApplicationPaths() []string {
+	return nerdGraphUnsupportedApplicationPaths
+}
+
+// GetApplicationHosts isn't supported by the NerdGraph backend.
+func (a *NerdGraphAPMClient) GetApplicationHosts(appID int) ([]nr.ApplicationHost, error) {
+	return nil, nerdGraphUnsupported("Application hosts")
+}
+
+// GetApplicationInstances isn't supported by the NerdGraph backend.
+func (a *NerdGraphAPMClient) GetApplicationInstances(appID int) ([]nr.ApplicationInstance, error) {
+	return nil, nerdGraphUnsupported("Application instances")
+}
+
+// GetApplicationDeployments isn't supported by the NerdGraph backend.
+func (a *NerdGraphAPMClient) GetApplicationDeployments(appID int) ([]Deployment, error) {
+	return nil, nerdGraphUnsupported("Application deployments")
+}
+
+// NerdGraphCustomClient is an implementation of a CustomClient using the NerdGraph API. Application metric data is
+// read from the metric timeslice data using NRQL, which requires the account id. The URL defaults to NerdGraphURL.
+type NerdGraphCustomClient struct {
+	APIKey    string
+	AccountID int
+	URL       string
+}
+
+// GetApplicationMetricData fetches application specific metric data.
+func (cc *NerdGraphCustomClient) GetApplicationMetricData(appID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	g := newNerdGraphClient(cc.APIKey, cc.URL)
+
+	selects := []string{}
+	for _, v := range nerdGraphMetricValues {
+		selects = append(selects, fmt.Sprintf("%s AS '%s'", v.Function, v.Name))
+	}
+
+	quotedNames := []string{}
+	for _, name := range names {
+		quotedNames = append(quotedNames, "'"+strings.Replace(name, "'", "\\'", -1)+"'")
+	}
+
+	// Without a timeframe New Relic defaults to the last 30 minutes.
+	timeframe := "SINCE 30 minutes ago"
+	if !options.From.IsZero() && !options.To.IsZero() {
+		timeframe = fmt.Sprintf("SINCE %d UNTIL %d", options.From.UnixNano()/int64(time.Millisecond), options.To.UnixNano()/int64(time.Millisecond))
+	}
+
+	// The full series is requested as a timeseries, one row per metric name and timeslice.
+	timeseries := ""
+	if !options.Summarize && options.Period > 0 {
+		timeseries = fmt.Sprintf(" TIMESERIES %d seconds", options.Period)
+	} else if !options.Summarize {
+		timeseries = " TIMESERIES AUTO"
+	}
+
+	nrql := fmt.Sprintf(
+		"SELECT %s FROM Metric WHERE appId = %d AND metricTimesliceName IN (%s) FACET metricTimesliceName %s%s LIMIT MAX",
+		strings.Join(selects, ", "),
+		appID,
+		strings.Join(quotedNames, ", "),
+		timeframe,
+		timeseries,
+	)
+
+	rows, err := g.nrql(cc.AccountID, nrql)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &nr.MetricDataResponse{
+		From:    options.From,
+		To:      options.To,
+		Metrics: []nr.MetricData{},
+	}
+
+	metricIndex := map[string]int{}
+	for _, row := range rows {
+		name, ok := row["metricTimesliceName"].(string)
+		if !ok {
+			continue
+		}
+
+		timeslice := nr.MetricTimeslice{
+			From:   options.From,
+			To:     options.To,
+			Values: map[string]float64{},
+		}
+
+		if begin, ok := row["beginTimeSeconds"].(float64); ok {
+			timeslice.From = time.Unix(int64(begin), 0).UTC()
+		}
+
+		if end, ok := row["endTimeSeconds"].(float64); ok {
+			timeslice.To = time.Unix(int64(end), 0).UTC()
+		}
+
+		for _, v := range nerdGraphMetricValues {
+			if value, ok := row[v.Name].(float64); ok {
+				timeslice.Values[v.Name] = value
+			}
+		}
+
+		if _, ok := metricIndex[name]; !ok {
+			metricIndex[name] = len(resp.Metrics)
+
+			resp.MetricsFound = append(resp.MetricsFound, name)
+			resp.Metrics = append(resp.Metrics, nr.MetricData{
+				Name: name,
+			})
+		}
+
+		metric := &resp.Metrics[metricIndex[name]]
+		metric.Timeslices = append(metric.Timeslices, timeslice)
+	}
+
+	for _, name := range names {
+		if _, ok := metricIndex[name]; !ok {
+			resp.MetricsNotFound = append(resp.MetricsNotFound, name)
+		}
+	}
+
+	return resp, nil
+}
+
+// GetComponentMetricData isn't supported by the NerdGraph backend.
+func (cc *NerdGraphCustomClient) GetComponentMetricData(componentID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	return nil, nerdGraphUnsupported("Component metrics")
+}
+
+// GetApplicationHostMetricData isn't supported by the NerdGraph backend.
+func (cc *NerdGraphCustomClient) GetApplicationHostMetricData(appID int, hostID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	return nil, nerdGraphUnsupported("Application host metrics")
+}
+
+// GetApplicationInstanceMetricData isn't supported by the NerdGraph backend.
+func (cc *NerdGraphCustomClient) GetApplicationInstanceMetricData(appID int, instanceID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	return nil, nerdGraphUnsupported("Application instance metrics")
+}
+
+// GetMobileMetricData isn't supported by the NerdGraph backend.
+func (cc *NerdGraphCustomClient) GetMobileMetricData(mobileAppID int, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	return nil, nerdGraphUnsupported("Mobile metrics")
+}
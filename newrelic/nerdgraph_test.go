@@ -0,0 +1,416 @@
+package newrelic_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/inteleon/snap-plugin-collector-newrelic/newrelic"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	nr "github.com/yfronto/newrelic"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// nerdGraphTestServer is a fake NerdGraph API. Application listings are served in two pages, a search for the
+// application 1337 by its domain id is answered with that application only, NRQL queries are answered with the
+// configured rows.
+type nerdGraphTestServer struct {
+	mu           sync.Mutex
+	entityCalls  int
+	searches     []interface{}
+	cursors      []interface{}
+	nrqlQueries  []string
+	nrqlRows     []map[string]interface{}
+	apiKeyHeader string
+}
+
+func (s *nerdGraphTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiKeyHeader = r.Header.Get("API-Key")
+
+	req := struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if strings.Contains(req.Query, "entitySearch") {
+		s.entityCalls++
+		s.searches = append(s.searches, req.Variables["query"])
+		s.cursors = append(s.cursors, req.Variables["cursor"])
+
+		if search, _ := req.Variables["query"].(string); strings.Contains(search, "domainId") {
+			entities := ""
+			if strings.Contains(search, "domainId = '1337'") {
+				entities = `{"applicationId": 1337, "name": "hax", "language": "go", "reporting": true, "alertSeverity": "CRITICAL",
+				 "apmSummary": {"apdexScore": 0.9, "errorRate": 0.015, "hostCount": 2, "instanceCount": 3, "responseTimeAverage": 0.25, "throughput": 120}}`
+			}
+
+			fmt.Fprintf(w, `{"data": {"actor": {"entitySearch": {"results": {"nextCursor": null, "entities": [%s]}}}}}`, entities)
+
+			return
+		}
+
+		if req.Variables["cursor"] == nil {
+			fmt.Fprint(w, `{"data": {"actor": {"entitySearch": {"results": {"nextCursor": "page-2", "entities": [
+				{"applicationId": 1337, "name": "hax", "language": "go", "reporting": true, "alertSeverity": "CRITICAL",
+				 "apmSummary": {"apdexScore": 0.9, "errorRate": 0.015, "hostCount": 2, "instanceCount": 3, "responseTimeAverage": 0.25, "throughput": 120}}
+			]}}}}}`)
+
+			return
+		}
+
+		fmt.Fprint(w, `{"data": {"actor": {"entitySearch": {"results": {"nextCursor": null, "entities": [
+			{"applicationId": 1234, "name": "leet", "language": "java", "reporting": false, "alertSeverity": "SOMETHING_NEW",
+			 "apmSummary": {"apdexScore": 1, "errorRate": 0, "hostCount": 1, "instanceCount": 1, "responseTimeAverage": 0.1, "throughput": 5}}
+		]}}}}}`)
+
+		return
+	}
+
+	nrql, _ := req.Variables["nrql"].(string)
+	s.nrqlQueries = append(s.nrqlQueries, nrql)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{
+			"actor": map[string]interface{}{
+				"account": map[string]interface{}{
+					"nrql": map[string]interface{}{
+						"results": s.nrqlRows,
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestNerdGraphUnsupportedMetrics(t *testing.T) {
+	apmClient := &newrelic.NerdGraphAPMClient{}
+
+	if _, err := apmClient.GetApplicationHosts(1337); err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+
+	if _, err := apmClient.GetApplicationInstances(1337); err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+
+	if _, err := apmClient.GetApplicationDeployments(1337); err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+
+	customClient := &newrelic.NerdGraphCustomClient{}
+
+	if _, err := customClient.GetComponentMetricData(1337, []string{"Component/foo"}, &nr.MetricDataOptions{}); err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+
+	if _, err := customClient.GetMobileMetricData(1337, []string{"Mobile/foo"}, &nr.MetricDataOptions{}); err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+}
+
+func TestNerdGraphMetricDataMissingAccountID(t *testing.T) {
+	customClient := &newrelic.NerdGraphCustomClient{
+		APIKey: "secret",
+	}
+
+	_, err := customClient.GetApplicationMetricData(1337, []string{"HttpDispatcher"}, &nr.MetricDataOptions{})
+	if err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+}
+
+func TestNerdGraphGetApplicationsSuccess(t *testing.T) {
+	server := &nerdGraphTestServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	apmClient := &newrelic.NerdGraphAPMClient{
+		APIKey: "secret",
+		URL:    ts.URL,
+	}
+
+	apps, err := apmClient.GetApplications()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(apps) != 2 {
+		t.Fatal("expected", 2, "got", len(apps))
+	}
+
+	// Both pages are fetched, the second one using the cursor of the first.
+	if fmt.Sprint(server.cursors) != fmt.Sprint([]interface{}{nil, "page-2"}) {
+		t.Fatal("expected", []interface{}{nil, "page-2"}, "got", server.cursors)
+	}
+
+	if server.apiKeyHeader != "secret" {
+		t.Fatal("expected", "secret", "got", server.apiKeyHeader)
+	}
+
+	app := apps[0]
+	if app.ID != 1337 || app.Name != "hax" || app.Language != "go" || !app.Reporting {
+		t.Fatal("expected", "application hax", "got", app)
+	}
+
+	// Response times are converted from seconds to milliseconds, error rates from ratios to percentages.
+	if app.ApplicationSummary.ResponseTime != 250 {
+		t.Fatal("expected", 250, "got", app.ApplicationSummary.ResponseTime)
+	}
+
+	if app.ApplicationSummary.ErrorRate != 1.5 {
+		t.Fatal("expected", 1.5, "got", app.ApplicationSummary.ErrorRate)
+	}
+
+	if app.ApplicationSummary.Throughput != 120 || app.ApplicationSummary.ApdexScore != 0.9 {
+		t.Fatal("expected", "throughput 120 and apdex score 0.9", "got", app.ApplicationSummary)
+	}
+
+	if app.ApplicationSummary.HostCount != 2 || app.ApplicationSummary.InstanceCount != 3 {
+		t.Fatal("expected", "2 hosts and 3 instances", "got", app.ApplicationSummary)
+	}
+
+	expectedHealthStatus := []string{"red", "unknown"}
+	for i, e := range expectedHealthStatus {
+		if apps[i].HealthStatus != e {
+			t.Fatal("expected", e, "got", apps[i].HealthStatus)
+		}
+	}
+}
+
+func TestNerdGraphGetApplicationSuccess(t *testing.T) {
+	server := &nerdGraphTestServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	apmClient := &newrelic.NerdGraphAPMClient{
+		APIKey: "secret",
+		URL:    ts.URL,
+	}
+
+	for i := 0; i < 2; i++ {
+		app, err := apmClient.GetApplication(1337)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if app.ID != 1337 || app.ApplicationSummary.ResponseTime != 250 {
+			t.Fatal("expected", "application 1337", "got", app)
+		}
+	}
+
+	// The application is queried on every lookup, so its values are current.
+	if server.entityCalls != 2 {
+		t.Fatal("expected", 2, "got", server.entityCalls)
+	}
+
+	expectedSearch := "domain = 'APM' AND type = 'APPLICATION' AND domainId = '1337'"
+	if server.searches[0] != expectedSearch {
+		t.Fatal("expected", expectedSearch, "got", server.searches[0])
+	}
+
+	if _, err := apmClient.GetApplication(4242); err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+}
+
+func TestNerdGraphCollectAppMetricsUnsupportedFields(t *testing.T) {
+	server := &nerdGraphTestServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	a := &newrelic.APM{
+		APMClient: &newrelic.NerdGraphAPMClient{
+			APIKey: "secret",
+			URL:    ts.URL,
+		},
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "1337", "show", "summary", "application", "response_time"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Path": "ApplicationSummary/ResponseTime",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "1337", "show", "summary", "application", "apdex_target"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Path": "ApplicationSummary/ApdexTarget",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "apm", "application", "1337", "show", "summary", "enduser", "response_time"),
+			Tags: map[string]string{
+				"Type": "application",
+				"Path": "EndUserSummary/ResponseTime",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := a.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The apdex target and end user summary aren't supplied by NerdGraph, they're left out rather than reported as 0.
+	if len(ret) != 1 {
+		t.Fatal("expected", 1, "got", len(ret))
+	}
+
+	if ret[0].Data.(float64) != 250 {
+		t.Fatal("expected", 250, "got", ret[0].Data.(float64))
+	}
+}
+
+func TestNerdGraphGetApplicationMetricDataSuccess(t *testing.T) {
+	server := &nerdGraphTestServer{
+		nrqlRows: []map[string]interface{}{
+			{
+				"metricTimesliceName":   "HttpDispatcher",
+				"call_count":            42,
+				"average_response_time": 12.5,
+			},
+			{
+				"metricTimesliceName": "Datastore/all",
+				"call_count":          7,
+			},
+		},
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	customClient := &newrelic.NerdGraphCustomClient{
+		APIKey:    "secret",
+		AccountID: 1234567,
+		URL:       ts.URL,
+	}
+
+	from := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	to := from.Add(30 * time.Minute)
+
+	resp, err := customClient.GetApplicationMetricData(
+		1337,
+		[]string{"HttpDispatcher", "Datastore/all", "Missing/metric"},
+		&nr.MetricDataOptions{From: from, To: to, Summarize: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(server.nrqlQueries) != 1 {
+		t.Fatal("expected", 1, "got", len(server.nrqlQueries))
+	}
+
+	nrql := server.nrqlQueries[0]
+	for _, e := range []string{"appId = 1337", "'HttpDispatcher', 'Datastore/all', 'Missing/metric'", "FACET metricTimesliceName", "SINCE 1483272000000 UNTIL 1483273800000"} {
+		if !strings.Contains(nrql, e) {
+			t.Fatal("expected", e, "got", nrql)
+		}
+	}
+
+	if strings.Contains(nrql, "TIMESERIES") {
+		t.Fatal("expected", "no TIMESERIES", "got", nrql)
+	}
+
+	if len(resp.Metrics) != 2 {
+		t.Fatal("expected", 2, "got", len(resp.Metrics))
+	}
+
+	if resp.Metrics[0].Name != "HttpDispatcher" || len(resp.Metrics[0].Timeslices) != 1 {
+		t.Fatal("expected", "one HttpDispatcher timeslice", "got", resp.Metrics[0])
+	}
+
+	timeslice := resp.Metrics[0].Timeslices[0]
+	if timeslice.Values["call_count"] != 42 || timeslice.Values["average_response_time"] != 12.5 {
+		t.Fatal("expected", "call_count 42 and average_response_time 12.5", "got", timeslice.Values)
+	}
+
+	if !timeslice.From.Equal(from) || !timeslice.To.Equal(to) {
+		t.Fatal("expected", from, to, "got", timeslice.From, timeslice.To)
+	}
+
+	if fmt.Sprint(resp.MetricsFound) != fmt.Sprint([]string{"HttpDispatcher", "Datastore/all"}) {
+		t.Fatal("expected", []string{"HttpDispatcher", "Datastore/all"}, "got", resp.MetricsFound)
+	}
+
+	if fmt.Sprint(resp.MetricsNotFound) != fmt.Sprint([]string{"Missing/metric"}) {
+		t.Fatal("expected", []string{"Missing/metric"}, "got", resp.MetricsNotFound)
+	}
+}
+
+func TestNerdGraphGetApplicationMetricDataTimeseriesSuccess(t *testing.T) {
+	from := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	server := &nerdGraphTestServer{
+		nrqlRows: []map[string]interface{}{
+			{
+				"metricTimesliceName": "HttpDispatcher",
+				"beginTimeSeconds":    from.Unix(),
+				"endTimeSeconds":      from.Add(time.Minute).Unix(),
+				"call_count":          1,
+			},
+			{
+				"metricTimesliceName": "HttpDispatcher",
+				"beginTimeSeconds":    from.Add(time.Minute).Unix(),
+				"endTimeSeconds":      from.Add(2 * time.Minute).Unix(),
+				"call_count":          2,
+			},
+		},
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	customClient := &newrelic.NerdGraphCustomClient{
+		APIKey:    "secret",
+		AccountID: 1234567,
+		URL:       ts.URL,
+	}
+
+	resp, err := customClient.GetApplicationMetricData(1337, []string{"HttpDispatcher"}, &nr.MetricDataOptions{Period: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nrql := server.nrqlQueries[0]
+	for _, e := range []string{"SINCE 30 minutes ago", "TIMESERIES 60 seconds"} {
+		if !strings.Contains(nrql, e) {
+			t.Fatal("expected", e, "got", nrql)
+		}
+	}
+
+	if len(resp.Metrics) != 1 || len(resp.Metrics[0].Timeslices) != 2 {
+		t.Fatal("expected", "two HttpDispatcher timeslices", "got", resp.Metrics)
+	}
+
+	for i, timeslice := range resp.Metrics[0].Timeslices {
+		expectedFrom := from.Add(time.Duration(i) * time.Minute)
+		if !timeslice.From.Equal(expectedFrom) || !timeslice.To.Equal(expectedFrom.Add(time.Minute)) {
+			t.Fatal("expected", expectedFrom, "got", timeslice.From)
+		}
+
+		if timeslice.Values["call_count"] != float64(i+1) {
+			t.Fatal("expected", float64(i+1), "got", timeslice.Values["call_count"])
+		}
+	}
+
+	if len(resp.MetricsNotFound) != 0 {
+		t.Fatal("expected", 0, "got", len(resp.MetricsNotFound))
+	}
+}
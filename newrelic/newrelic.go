@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,6 +16,22 @@ type Metric struct {
 	Unit      string
 }
 
+// The backends the APM and metric data clients can be picked from with the backend config.
+const (
+	BackendREST      = "rest"
+	BackendNerdGraph = "nerdgraph"
+)
+
+// DefaultWorkers is the number of fetches run concurrently when the workers config isn't set.
+const DefaultWorkers = 4
+
+// The timestamp sources the timestamp_source config can be set to. Source timestamps are the time of the New Relic
+// data a metric describes, collection timestamps the time the metric is collected.
+const (
+	TimestampSourceSource     = "source"
+	TimestampSourceCollection = "collection"
+)
+
 // Service is the interface every New Relic service component must implement.
 type Service interface {
 	GetMetricTypes(plugin.Config) ([]plugin.Metric, error)
@@ -22,7 +39,10 @@ type Service interface {
 }
 
 // Collector takes care of fetching data from New Relic.
-type Collector struct{}
+type Collector struct {
+	mu           sync.Mutex
+	applications map[string]*Applications
+}
 
 // GetConfigPolicy defines the configuration variables this plugin supports.
 func (n *Collector) GetConfigPolicy() (plugin.ConfigPolicy, error) {
@@ -32,6 +52,74 @@ func (n *Collector) GetConfigPolicy() (plugin.ConfigPolicy, error) {
 		"api_key",
 		true,
 	)
+	p.AddNewIntRule(
+		[]string{"inteleon", "newrelic"},
+		"account_id",
+		false,
+	)
+	p.AddNewStringRule(
+		[]string{"inteleon", "newrelic"},
+		"query_key",
+		false,
+	)
+	p.AddNewStringRule(
+		[]string{"inteleon", "newrelic", "nrql"},
+		"nrql",
+		false,
+	)
+	p.AddNewStringRule(
+		[]string{"inteleon", "newrelic"},
+		"metric_name_apps",
+		false,
+	)
+	p.AddNewStringRule(
+		[]string{"inteleon", "newrelic"},
+		"metric_name_filter",
+		false,
+	)
+	p.AddNewBoolRule(
+		[]string{"inteleon", "newrelic"},
+		"summarize",
+		false,
+		plugin.SetDefaultBool(true),
+	)
+	p.AddNewIntRule(
+		[]string{"inteleon", "newrelic"},
+		"period",
+		false,
+		plugin.SetDefaultInt(0),
+	)
+	p.AddNewBoolRule(
+		[]string{"inteleon", "newrelic"},
+		"align",
+		false,
+		plugin.SetDefaultBool(false),
+	)
+	p.AddNewIntRule(
+		[]string{"inteleon", "newrelic"},
+		"lag_seconds",
+		false,
+		plugin.SetDefaultInt(0),
+		plugin.SetMinInt(0),
+	)
+	p.AddNewStringRule(
+		[]string{"inteleon", "newrelic"},
+		"timestamp_source",
+		false,
+		plugin.SetDefaultString(TimestampSourceSource),
+	)
+	p.AddNewIntRule(
+		[]string{"inteleon", "newrelic"},
+		"workers",
+		false,
+		plugin.SetDefaultInt(DefaultWorkers),
+	)
+	p.AddNewStringRule(
+		[]string{"inteleon", "newrelic"},
+		"backend",
+		false,
+		plugin.SetDefaultString(BackendREST),
+	)
 
 	return *p, nil
 }
@@ -40,7 +128,12 @@ func (n *Collector) GetConfigPolicy() (plugin.ConfigPolicy, error) {
 func (n *Collector) GetMetricTypes(cfg plugin.Config) ([]plugin.Metric, error) {
 	ret := []plugin.Metric{}
 
-	for _, comp := range []Service{NewAPM(""), NewCustom("")} {
+	services, err := n.services(cfg, nil)
+	if err != nil {
+		return ret, err
+	}
+
+	for _, comp := range services {
 		met, err := comp.GetMetricTypes(cfg)
 		if err != nil {
 			return ret, err
@@ -60,21 +153,102 @@ func (n *Collector) CollectMetrics(metrics []plugin.Metric) ([]plugin.Metric, er
 
 	cfg := metrics[0].Config
 
-	for _, comp := range []Service{NewAPM(cfg["api_key"].(string)), NewCustom(cfg["api_key"].(string))} {
-		met, err := comp.CollectMetrics(metrics)
-		if err != nil {
-			return ret, err
+	// The workers are shared by the services, so the workers config limits the fetches of the whole collection.
+	workers := NewWorkers(configuredWorkers(metrics))
+
+	services, err := n.services(cfg, workers)
+	if err != nil {
+		return ret, err
+	}
+
+	// The services collect concurrently, their metrics are returned in the order of the services.
+	collected := make([][]plugin.Metric, len(services))
+	errs := workers.parallel(len(services), func(i int) error {
+		met, err := services[i].CollectMetrics(metrics)
+		collected[i] = met
+
+		return err
+	})
+
+	failures := []string{}
+	for i := range services {
+		if errs[i] != nil {
+			failures = append(failures, errs[i].Error())
+
+			continue
 		}
 
-		for _, m := range met {
+		for _, m := range collected[i] {
 			ret = append(ret, m)
 		}
 	}
 
+	if len(failures) > 0 {
+		return ret, fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+
 	return ret, nil
 }
 
-func populateMetric(metric plugin.Metric, mapData map[string]interface{}) (plugin.Metric, error) {
+// services returns all the New Relic services, sharing one applications lookup per backend and API key between them.
+func (n *Collector) services(cfg plugin.Config, workers *Workers) ([]Service, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	apiKey, _ := cfg.GetString("api_key")
+	accountID, _ := cfg.GetInt("account_id")
+	queryKey, _ := cfg.GetString("query_key")
+	backend, _ := cfg.GetString("backend")
+
+	if timestampSource, err := cfg.GetString("timestamp_source"); err == nil {
+		if timestampSource != TimestampSourceSource && timestampSource != TimestampSourceCollection {
+			return nil, fmt.Errorf("Unknown timestamp source: %s", timestampSource)
+		}
+	}
+
+	apmClient, customClient, err := backendClients(backend, apiKey, int(accountID))
+	if err != nil {
+		return nil, err
+	}
+
+	if n.applications == nil {
+		n.applications = map[string]*Applications{}
+	}
+
+	appsKey := backend + "/" + apiKey
+	if _, ok := n.applications[appsKey]; !ok {
+		n.applications[appsKey] = NewApplications(apmClient)
+	}
+
+	apps := n.applications[appsKey]
+
+	return []Service{
+		NewAPM(apmClient, apps, workers),
+		NewCustom(customClient, &MetricNamesClientImpl{APIKey: apiKey}, &MobileClientImpl{APIKey: apiKey}, apps, workers),
+		NewKeyTransactions(apiKey),
+		NewBrowser(apiKey, workers),
+		NewMobile(apiKey),
+		NewAlerts(apiKey),
+		NewAlertInventory(apiKey, apps, workers),
+		NewSynthetics(apiKey, int(accountID), queryKey),
+		NewInfrastructure(int(accountID), queryKey, workers),
+		NewNRQL(int(accountID), queryKey),
+	}, nil
+}
+
+// backendClients returns the APM and metric data clients of the given backend. The REST backend is the default.
+func backendClients(backend string, apiKey string, accountID int) (APMClient, CustomClient, error) {
+	switch backend {
+	case "", BackendREST:
+		return &APMClientImpl{APIKey: apiKey}, &CustomClientImpl{APIKey: apiKey}, nil
+	case BackendNerdGraph:
+		return &NerdGraphAPMClient{APIKey: apiKey}, &NerdGraphCustomClient{APIKey: apiKey, AccountID: accountID}, nil
+	}
+
+	return nil, nil, fmt.Errorf("Unknown backend: %s", backend)
+}
+
+func populateMetric(metric plugin.Metric, mapData map[string]interface{}, tags map[string]string) (plugin.Metric, error) {
 	// Create a new metric based on the "old" one.
 	newMetric := metric
 
@@ -93,11 +267,136 @@ func populateMetric(metric plugin.Metric, mapData map[string]interface{}) (plugi
 	newMetric.Data = metricData
 	newMetric.Unit = metric.Tags["Unit"]
 	newMetric.Tags = map[string]string{}
+	for k, v := range tags {
+		newMetric.Tags[k] = v
+	}
 	newMetric.Timestamp = time.Now().UTC()
 
 	return newMetric, nil
 }
 
+// withSourceTimestamp returns a copy of the populated metric timestamped at the time of the data it describes. The
+// collection time set by populateMetric is kept when the source time is unknown or collection timestamps are
+// configured.
+func withSourceTimestamp(metric plugin.Metric, sourceTime time.Time) plugin.Metric {
+	timestampSource, _ := metric.Config.GetString("timestamp_source")
+	if sourceTime.IsZero() || timestampSource == TimestampSourceCollection {
+		return metric
+	}
+
+	newMetric := metric
+	newMetric.Timestamp = sourceTime.UTC()
+
+	return newMetric
+}
+
+// configuredWorkers returns the number of fetches to run concurrently for the requested metrics. The workers config is
+// a plugin wide option, Snap merges the global plugin config into the config of every metric, so it's read from the
+// first metric.
+func configuredWorkers(metrics []plugin.Metric) int {
+	if len(metrics) == 0 {
+		return DefaultWorkers
+	}
+
+	return configWorkers(metrics[0].Config)
+}
+
+// configWorkers returns the number of fetches to run concurrently for the config.
+func configWorkers(cfg plugin.Config) int {
+	workers, err := cfg.GetInt("workers")
+	if err != nil || workers < 1 {
+		return DefaultWorkers
+	}
+
+	return int(workers)
+}
+
+// Workers limits the number of fetches running at the same time during a collection. Every fetch runs on a worker,
+// a function waiting on the fetches it runs in parallel gives up its worker while waiting, so the limit holds across
+// the services and the entities they fetch.
+type Workers struct {
+	sem chan struct{}
+}
+
+// NewWorkers creates and returns Workers running at most n functions at the same time. The caller holds one of the
+// workers.
+func NewWorkers(n int) *Workers {
+	if n < 1 {
+		n = 1
+	}
+
+	w := &Workers{
+		sem: make(chan struct{}, n),
+	}
+	w.sem <- struct{}{}
+
+	return w
+}
+
+// serviceWorkers returns the workers of the collection a service runs in, or workers of its own when the service
+// collects on its own.
+func serviceWorkers(workers *Workers, metrics []plugin.Metric) *Workers {
+	if workers != nil {
+		return workers
+	}
+
+	return NewWorkers(configuredWorkers(metrics))
+}
+
+// parallel calls fn for every index below n, each on a worker, starting them in order. It's called holding a worker,
+// which is given up while waiting on the calls. The errors are returned by index, so callers handle them in the same
+// order as when running serially.
+func (w *Workers) parallel(n int, fn func(int) error) []error {
+	errs := make([]error, n)
+
+	<-w.sem
+	defer func() { w.sem <- struct{}{} }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		w.sem <- struct{}{}
+
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-w.sem }()
+
+			errs[i] = fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return errs
+}
+
+// firstError returns the first error of the list, if any.
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mapDelete removes the value at the path from the map data, if present.
+func mapDelete(mapData map[string]interface{}, path []string) {
+	if len(path) > 1 {
+		newMapData, ok := mapData[path[0]].(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		mapDelete(newMapData, path[1:])
+
+		return
+	}
+
+	delete(mapData, path[0])
+}
+
 func mapTraverse(mapData map[string]interface{}, path []string) (interface{}, error) {
 	pathElemNotFoundErrTemplate := "Path element not found: %s"
 
@@ -146,3 +445,42 @@ func metricTypes(namespace plugin.Namespace, metricsList []Metric) ([]plugin.Met
 
 	return metrics, nil
 }
+
+// withNamespaceValue returns a copy of the metrics list where the dynamic namespace element with the given name is
+// replaced by a static element holding the given value.
+func withNamespaceValue(metricsList []Metric, name string, value string) []Metric {
+	ret := []Metric{}
+
+	for _, m := range metricsList {
+		newMetric := m
+		newMetric.Namespace = plugin.Namespace{}
+
+		for _, e := range m.Namespace {
+			if e.Name == name {
+				e = plugin.NewNamespaceElement(value)
+			}
+
+			newMetric.Namespace = append(newMetric.Namespace, e)
+		}
+
+		ret = append(ret, newMetric)
+	}
+
+	return ret
+}
+
+// withElementValue returns a copy of the metric where the namespace element at the given index holds the given value.
+func withElementValue(metric plugin.Metric, idx int, value string) plugin.Metric {
+	newMetric := metric
+	newMetric.Namespace = plugin.Namespace{}
+
+	for i, e := range metric.Namespace {
+		if i == idx {
+			e.Value = value
+		}
+
+		newMetric.Namespace = append(newMetric.Namespace, e)
+	}
+
+	return newMetric
+}
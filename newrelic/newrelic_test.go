@@ -0,0 +1,136 @@
+package newrelic_test
+
+import (
+	"fmt"
+	"github.com/inteleon/snap-plugin-collector-newrelic/newrelic"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"testing"
+)
+
+func TestCollectorUnknownBackend(t *testing.T) {
+	n := &newrelic.Collector{}
+
+	_, err := n.GetMetricTypes(plugin.Config{"backend": "soap"})
+	if err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+
+	expected := "Unknown backend: soap"
+	if err.Error() != expected {
+		t.Fatal("expected", expected, "got", err.Error())
+	}
+}
+
+func TestCollectorUnknownTimestampSource(t *testing.T) {
+	n := &newrelic.Collector{}
+
+	_, err := n.GetMetricTypes(plugin.Config{"timestamp_source": "collect"})
+	if err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+
+	expected := "Unknown timestamp source: collect"
+	if err.Error() != expected {
+		t.Fatal("expected", expected, "got", err.Error())
+	}
+}
+
+// workersMetrics returns metrics of four applications, with the config.
+func workersMetrics(cfg plugin.Config) []plugin.Metric {
+	metrics := []plugin.Metric{}
+	for appID := 1; appID <= 4; appID++ {
+		metrics = append(metrics, plugin.Metric{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "metric", "application", fmt.Sprint(appID), "5", "HttpDispatcher", "call_count", "value"),
+			Config:    cfg,
+			Tags: map[string]string{
+				"Type": "application",
+				"Unit": "float",
+			},
+		})
+	}
+
+	return metrics
+}
+
+func TestWorkersSingleWorker(t *testing.T) {
+	customClient := &parallelCustomClientTestImpl{}
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+		Workers:      newrelic.NewWorkers(1),
+	}
+
+	ret, err := c.CollectMetrics(workersMetrics(plugin.Config{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 4 {
+		t.Fatal("expected", 4, "got", len(ret))
+	}
+
+	if customClient.maxRunningFetches() > 1 {
+		t.Fatal("expected", 1, "got", customClient.maxRunningFetches())
+	}
+}
+
+func TestWorkersNonPositive(t *testing.T) {
+	customClient := &parallelCustomClientTestImpl{}
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+		Workers:      newrelic.NewWorkers(0),
+	}
+
+	// Less than one worker runs the fetches on a single worker.
+	ret, err := c.CollectMetrics(workersMetrics(plugin.Config{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 4 {
+		t.Fatal("expected", 4, "got", len(ret))
+	}
+
+	if customClient.maxRunningFetches() > 1 {
+		t.Fatal("expected", 1, "got", customClient.maxRunningFetches())
+	}
+}
+
+func TestConfiguredWorkers(t *testing.T) {
+	customClient := &parallelCustomClientTestImpl{}
+	c := &newrelic.Custom{
+		CustomClient: customClient,
+	}
+
+	// Without workers of a collection the workers config of the metrics is used.
+	_, err := c.CollectMetrics(workersMetrics(plugin.Config{"workers": int64(1)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if customClient.maxRunningFetches() > 1 {
+		t.Fatal("expected", 1, "got", customClient.maxRunningFetches())
+	}
+}
+
+func TestConfiguredWorkersDefault(t *testing.T) {
+	for _, cfg := range []plugin.Config{{}, {"workers": int64(0)}} {
+		customClient := &parallelCustomClientTestImpl{}
+		c := &newrelic.Custom{
+			CustomClient: customClient,
+		}
+
+		// A missing or invalid workers config runs the default number of workers.
+		ret, err := c.CollectMetrics(workersMetrics(cfg))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(ret) != 4 {
+			t.Fatal("expected", 4, "got", len(ret))
+		}
+
+		if customClient.maxRunningFetches() > newrelic.DefaultWorkers {
+			t.Fatal("expected", newrelic.DefaultWorkers, "got", customClient.maxRunningFetches())
+		}
+	}
+}
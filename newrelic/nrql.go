@@ -0,0 +1,182 @@
+package newrelic
+
+import (
+	"fmt"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// NRQLMetrics is a list containing the available NRQL metrics and their properties.
+var NRQLMetrics = []Metric{
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "query_name",
+				Description: "Name of the NRQL query, the query itself is set by the nrql config",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("value"),
+		},
+		Type: "value",
+		Path: "Value",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NamespaceElement{
+				Name:        "query_name",
+				Description: "Name of the NRQL query, the query itself is set by the nrql config",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("facet"),
+			plugin.NamespaceElement{
+				Name:        "facet",
+				Description: "Facet value of the NRQL query result",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("value"),
+		},
+		Type: "facet",
+		Path: "Value",
+		Unit: "float",
+	},
+}
+
+// NRQLClient is the interface every NRQL client needs to implement.
+type NRQLClient interface {
+	Query(string) (*InsightsResponse, error)
+}
+
+// NRQLClientImpl is a real implementation of a NRQLClient.
+type NRQLClientImpl struct {
+	AccountID int
+	QueryKey  string
+}
+
+// Query runs the NRQL query using the Insights query API.
+func (nc *NRQLClientImpl) Query(nrql string) (*InsightsResponse, error) {
+	return queryInsights(nc.AccountID, nc.QueryKey, nrql)
+}
+
+// NRQL represents the NRQL query service part of New Relic.
+type NRQL struct {
+	NRQLClient NRQLClient
+}
+
+// NewNRQL creates and returns a new NRQL object with a configured NRQLClient.
+func NewNRQL(accountID int, queryKey string) Service {
+	return &NRQL{
+		NRQLClient: &NRQLClientImpl{
+			AccountID: accountID,
+			QueryKey:  queryKey,
+		},
+	}
+}
+
+// GetMetricTypes returns the available NRQL metric types.
+func (nq *NRQL) GetMetricTypes(_ plugin.Config) ([]plugin.Metric, error) {
+	return metricTypes(plugin.NewNamespace("inteleon", "newrelic", "nrql"), NRQLMetrics)
+}
+
+// CollectMetrics runs the NRQL queries of the requested metrics and returns their results.
+// A single-value result is reported as is, the results of a FACET query are reported per facet.
+func (nq *NRQL) CollectMetrics(metrics []plugin.Metric) ([]plugin.Metric, error) {
+	collectedMetrics := []plugin.Metric{}
+
+	if len(metrics) == 0 {
+		return collectedMetrics, fmt.Errorf("List of metrics is empty")
+	}
+
+	responses := map[string]*InsightsResponse{}
+	for i, m := range metrics {
+		if m.Namespace.Element(2).Value != "nrql" {
+			continue
+		}
+
+		queryName := m.Namespace.Element(3).Value
+
+		nrql, err := m.Config.GetString("nrql")
+		if err != nil {
+			return collectedMetrics, fmt.Errorf("NRQL query missing for query name: %s", queryName)
+		}
+
+		if _, ok := responses[nrql]; !ok {
+			// Query result missing, fetching...
+			resp, err := nq.NRQLClient.Query(nrql)
+			if err != nil {
+				return collectedMetrics, err
+			}
+
+			responses[nrql] = resp
+		}
+
+		resp := responses[nrql]
+
+		switch m.Tags["Type"] {
+		case "value":
+			if len(resp.Results) == 0 {
+				// Metric not found, skip reporting it and continue execution.
+				continue
+			}
+
+			value, ok := insightsValue(resp.Results[0])
+			if !ok {
+				// Metric not found, skip reporting it and continue execution.
+				continue
+			}
+
+			populatedMetric, err := populateMetric(
+				metrics[i],
+				map[string]interface{}{"Value": value},
+				map[string]string{"query_name": queryName},
+			)
+			if err != nil {
+				// Metric not found, skip reporting it and continue execution.
+				continue
+			}
+
+			collectedMetrics = append(collectedMetrics, populatedMetric)
+
+			break
+		case "facet":
+			facet := m.Namespace.Element(5).Value
+			for _, f := range resp.Facets {
+				facetName := f.FacetName()
+				if facet != "*" && facet != facetName {
+					continue
+				}
+
+				if len(f.Results) == 0 {
+					// Metric not found, skip reporting it and continue execution.
+					continue
+				}
+
+				value, ok := insightsValue(f.Results[0])
+				if !ok {
+					// Metric not found, skip reporting it and continue execution.
+					continue
+				}
+
+				populatedMetric, err := populateMetric(
+					withElementValue(metrics[i], 5, facetName),
+					map[string]interface{}{"Value": value},
+					map[string]string{
+						"query_name": queryName,
+						"facet":      facetName,
+					},
+				)
+				if err != nil {
+					// Metric not found, skip reporting it and continue execution.
+					continue
+				}
+
+				collectedMetrics = append(collectedMetrics, populatedMetric)
+			}
+
+			break
+		default:
+			return collectedMetrics, fmt.Errorf("Unknown metric type: %s", m.Tags["Type"])
+		}
+	}
+
+	return collectedMetrics, nil
+}
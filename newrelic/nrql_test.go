@@ -0,0 +1,165 @@
+package newrelic_test
+
+import (
+	"fmt"
+	"github.com/inteleon/snap-plugin-collector-newrelic/newrelic"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"strings"
+	"testing"
+)
+
+type nrqlClientTestImpl struct {
+	queries []string
+}
+
+func (nc *nrqlClientTestImpl) Query(nrql string) (*newrelic.InsightsResponse, error) {
+	nc.queries = append(nc.queries, nrql)
+
+	if strings.Contains(nrql, "FACET") {
+		return &newrelic.InsightsResponse{
+			Facets: []newrelic.InsightsFacet{
+				{
+					Name: "checkout",
+					Results: []map[string]interface{}{
+						{"count": 13.0},
+					},
+				},
+				{
+					Name: []interface{}{"search", "eu"},
+					Results: []map[string]interface{}{
+						{"count": 37.0},
+					},
+				},
+			},
+		}, nil
+	}
+
+	return &newrelic.InsightsResponse{
+		Results: []map[string]interface{}{
+			{"percentiles": map[string]interface{}{"95": 13.37}},
+		},
+	}, nil
+}
+
+func TestGetNRQLMetricTypesSuccess(t *testing.T) {
+	nq := &newrelic.NRQL{}
+
+	metrics, err := nq.GetMetricTypes(plugin.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLen := len(newrelic.NRQLMetrics)
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	for i, m := range metrics {
+		expectedNS := fmt.Sprintf("inteleon/newrelic/nrql/%s", strings.Join(newrelic.NRQLMetrics[i].Namespace.Strings(), "/"))
+		ns := strings.Join(m.Namespace.Strings(), "/")
+
+		if ns != expectedNS {
+			t.Fatal("expected", expectedNS, "got", ns)
+		}
+	}
+}
+
+func TestCollectNRQLMetricsSuccess(t *testing.T) {
+	nrqlClient := &nrqlClientTestImpl{}
+
+	nq := &newrelic.NRQL{
+		NRQLClient: nrqlClient,
+	}
+
+	facetQuery := "SELECT count(*) FROM Transaction FACET name"
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "nrql", "duration_p95", "value"),
+			Config:    plugin.Config{"nrql": "SELECT percentile(duration, 95) FROM Transaction"},
+			Tags: map[string]string{
+				"Type": "value",
+				"Path": "Value",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "nrql", "transactions", "facet", "*", "value"),
+			Config:    plugin.Config{"nrql": facetQuery},
+			Tags: map[string]string{
+				"Type": "facet",
+				"Path": "Value",
+				"Unit": "float",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "nrql", "transactions", "facet", "checkout", "value"),
+			Config:    plugin.Config{"nrql": facetQuery},
+			Tags: map[string]string{
+				"Type": "facet",
+				"Path": "Value",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := nq.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 4 {
+		t.Fatal("expected", 4, "got", len(ret))
+	}
+
+	expected := []struct {
+		ns   string
+		data interface{}
+	}{
+		{"inteleon/newrelic/nrql/duration_p95/value", 13.37},
+		{"inteleon/newrelic/nrql/transactions/facet/checkout/value", 13.0},
+		{"inteleon/newrelic/nrql/transactions/facet/search,eu/value", 37.0},
+		{"inteleon/newrelic/nrql/transactions/facet/checkout/value", 13.0},
+	}
+	for i, e := range expected {
+		ns := strings.Join(ret[i].Namespace.Strings(), "/")
+		if ns != e.ns {
+			t.Fatal("expected", e.ns, "got", ns)
+		}
+
+		if ret[i].Data != e.data {
+			t.Fatal("expected", e.data, "got", ret[i].Data)
+		}
+	}
+
+	if ret[2].Tags["facet"] != "search,eu" {
+		t.Fatal("expected", "search,eu", "got", ret[2].Tags["facet"])
+	}
+
+	if len(nrqlClient.queries) != 2 {
+		t.Fatal("expected", 2, "got", len(nrqlClient.queries))
+	}
+}
+
+func TestCollectNRQLMetricsMissingQuery(t *testing.T) {
+	nq := &newrelic.NRQL{
+		NRQLClient: &nrqlClientTestImpl{},
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "nrql", "duration_p95", "value"),
+			Config:    plugin.Config{},
+			Tags: map[string]string{
+				"Type": "value",
+				"Path": "Value",
+				"Unit": "float",
+			},
+		},
+	}
+
+	_, err := nq.CollectMetrics(metrics)
+	if err == nil {
+		t.Fatal("expected", "error", "got", nil)
+	}
+}
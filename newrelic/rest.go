@@ -0,0 +1,131 @@
+package newrelic
+
+import (
+	"encoding/json"
+	"fmt"
+	nr "github.com/yfronto/newrelic"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RESTBaseURL is the base URL of the New Relic REST API (v2).
+const RESTBaseURL = "https://api.newrelic.com/v2/"
+
+// RESTMaxPages is the maximum number of pages fetched from a paginated REST API endpoint.
+const RESTMaxPages = 100
+
+// HTTPTimeout is how long a request to a New Relic API may take before it's given up.
+const HTTPTimeout = 30 * time.Second
+
+// httpClient is the HTTP client shared by all the clients talking to the New Relic APIs.
+var httpClient = &http.Client{Timeout: HTTPTimeout}
+
+// newNRClient returns a New Relic Go library client using the shared HTTP client, so its requests time out as well.
+func newNRClient(apiKey string) *nr.Client {
+	return nr.NewWithHTTPClient(apiKey, httpClient)
+}
+
+// restClient talks to the parts of the New Relic APIs that the New Relic Go library doesn't cover.
+type restClient struct {
+	Key        string
+	KeyHeader  string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func newRESTClient(apiKey string) *restClient {
+	return &restClient{
+		Key:        apiKey,
+		KeyHeader:  "X-Api-Key",
+		BaseURL:    RESTBaseURL,
+		HTTPClient: httpClient,
+	}
+}
+
+// get fetches the given path and decodes the JSON response into out.
+func (r *restClient) get(path string, params url.Values, out interface{}) error {
+	req, err := http.NewRequest("GET", r.BaseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(r.KeyHeader, r.Key)
+
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		return fmt.Errorf("New Relic API request failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// getPages fetches the pages of a paginated endpoint one by one. The page function decodes a page and returns the
+// number of items on it, an empty page marks the end.
+func (r *restClient) getPages(path string, params url.Values, page func(func(interface{}) error) (int, error)) error {
+	for p := 1; p <= RESTMaxPages; p++ {
+		pageParams := url.Values{}
+		for k, v := range params {
+			pageParams[k] = v
+		}
+
+		pageParams.Set("page", strconv.Itoa(p))
+
+		n, err := page(func(out interface{}) error {
+			return r.get(path, pageParams, out)
+		})
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// getMetricData fetches metric data from the metric data endpoint at the given path.
+func (r *restClient) getMetricData(path string, names []string, options *nr.MetricDataOptions) (*nr.MetricDataResponse, error) {
+	params := url.Values{}
+	for _, name := range names {
+		params.Add("names[]", name)
+	}
+
+	if !options.From.IsZero() {
+		params.Set("from", options.From.Format(time.RFC3339))
+	}
+
+	if !options.To.IsZero() {
+		params.Set("to", options.To.Format(time.RFC3339))
+	}
+
+	if options.Period > 0 {
+		params.Set("period", strconv.Itoa(options.Period))
+	}
+
+	if options.Summarize {
+		params.Set("summarize", "true")
+	}
+
+	resp := struct {
+		MetricData nr.MetricDataResponse `json:"metric_data"`
+	}{}
+
+	if err := r.get(path, params, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp.MetricData, nil
+}
@@ -0,0 +1,364 @@
+package newrelic
+
+import (
+	"fmt"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"net/url"
+	"strconv"
+)
+
+// SyntheticsBaseURL is the base URL of the New Relic Synthetics API.
+const SyntheticsBaseURL = "https://synthetics.newrelic.com/synthetics/api/v3/"
+
+// SyntheticsDefaultMinutes is the timeframe used when the minutes namespace element is a wildcard. It matches the
+// default timeframe of the metric data metrics.
+const SyntheticsDefaultMinutes = 30
+
+// SyntheticsMetrics is a list containing the available Synthetics metrics and their properties.
+var SyntheticsMetrics = []Metric{
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("monitor"),
+			plugin.NamespaceElement{
+				Name:        "monitor_id",
+				Description: "Synthetics monitor id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("location"),
+			plugin.NamespaceElement{
+				Name:        "location",
+				Description: "Synthetics location",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "minutes",
+				Description: "Number of minutes to construct a relative timeframe from (now - minutes).",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("success"),
+		},
+		Type: "result",
+		Path: "Success",
+		Unit: "bool",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("monitor"),
+			plugin.NamespaceElement{
+				Name:        "monitor_id",
+				Description: "Synthetics monitor id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("location"),
+			plugin.NamespaceElement{
+				Name:        "location",
+				Description: "Synthetics location",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "minutes",
+				Description: "Number of minutes to construct a relative timeframe from (now - minutes).",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("duration"),
+		},
+		Type: "result",
+		Path: "Duration",
+		Unit: "float",
+	},
+	{
+		Namespace: plugin.Namespace{
+			plugin.NewNamespaceElement("monitor"),
+			plugin.NamespaceElement{
+				Name:        "monitor_id",
+				Description: "Synthetics monitor id",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("location"),
+			plugin.NamespaceElement{
+				Name:        "location",
+				Description: "Synthetics location",
+				Value:       "*",
+			},
+			plugin.NamespaceElement{
+				Name:        "minutes",
+				Description: "Number of minutes to construct a relative timeframe from (now - minutes).",
+				Value:       "*",
+			},
+			plugin.NewNamespaceElement("failures"),
+		},
+		Type: "result",
+		Path: "Failures",
+		Unit: "int",
+	},
+}
+
+// SyntheticsMonitor is a Synthetics monitor.
+type SyntheticsMonitor struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Frequency int      `json:"frequency"`
+	URI       string   `json:"uri"`
+	Locations []string `json:"locations"`
+	Status    string   `json:"status"`
+}
+
+// SyntheticsResult is the result of a Synthetics monitor at a single location over a timeframe.
+type SyntheticsResult struct {
+	MonitorID string
+	Location  string
+	Success   bool
+	Duration  float64
+	Failures  int
+}
+
+// SyntheticsClient is the interface every Synthetics client needs to implement.
+type SyntheticsClient interface {
+	GetMonitors() ([]SyntheticsMonitor, error)
+	GetMonitorResults(int) ([]SyntheticsResult, error)
+}
+
+// SyntheticsClientImpl is a real implementation of a SyntheticsClient.
+type SyntheticsClientImpl struct {
+	APIKey    string
+	AccountID int
+	QueryKey  string
+}
+
+// GetMonitors fetches all Synthetics monitors from New Relic.
+func (sc *SyntheticsClientImpl) GetMonitors() ([]SyntheticsMonitor, error) {
+	r := &restClient{
+		Key:        sc.APIKey,
+		KeyHeader:  "X-Api-Key",
+		BaseURL:    SyntheticsBaseURL,
+		HTTPClient: httpClient,
+	}
+
+	limit := 100
+	monitors := []SyntheticsMonitor{}
+	for page := 0; page < RESTMaxPages; page++ {
+		offset := page * limit
+
+		resp := struct {
+			Monitors []SyntheticsMonitor `json:"monitors"`
+		}{}
+
+		params := url.Values{
+			"offset": {strconv.Itoa(offset)},
+			"limit":  {strconv.Itoa(limit)},
+		}
+
+		if err := r.get("monitors", params, &resp); err != nil {
+			return nil, err
+		}
+
+		monitors = append(monitors, resp.Monitors...)
+
+		if len(resp.Monitors) < limit {
+			return monitors, nil
+		}
+	}
+
+	return monitors, nil
+}
+
+// GetMonitorResults fetches the results of all Synthetics monitors, per location, over the last number of minutes.
+// The results are read from the SyntheticCheck events using the Insights query API.
+func (sc *SyntheticsClientImpl) GetMonitorResults(minutes int) ([]SyntheticsResult, error) {
+	nrql := fmt.Sprintf(
+		"SELECT latest(result), latest(duration), filter(count(*), WHERE result = 'FAILED') FROM SyntheticCheck FACET monitorId, location SINCE %d minutes ago LIMIT 1000",
+		minutes,
+	)
+
+	resp, err := queryInsights(sc.AccountID, sc.QueryKey, nrql)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []SyntheticsResult{}
+	for _, facet := range resp.Facets {
+		names, ok := facet.Name.([]interface{})
+		if !ok || len(names) != 2 || len(facet.Results) != 3 {
+			continue
+		}
+
+		result := SyntheticsResult{
+			MonitorID: fmt.Sprint(names[0]),
+			Location:  fmt.Sprint(names[1]),
+		}
+
+		if status, ok := insightsValue(facet.Results[0]); ok {
+			result.Success = status == "SUCCESS"
+		}
+
+		if duration, ok := insightsValue(facet.Results[1]); ok {
+			result.Duration, _ = duration.(float64)
+		}
+
+		if failures, ok := insightsValue(facet.Results[2]); ok {
+			failuresFloat, _ := failures.(float64)
+			result.Failures = int(failuresFloat)
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// Synthetics represents the Synthetics service part of New Relic.
+type Synthetics struct {
+	SyntheticsClient SyntheticsClient
+}
+
+// NewSynthetics creates and returns a new Synthetics object with a configured SyntheticsClient.
+func NewSynthetics(apiKey string, accountID int, queryKey string) Service {
+	return &Synthetics{
+		SyntheticsClient: &SyntheticsClientImpl{
+			APIKey:    apiKey,
+			AccountID: accountID,
+			QueryKey:  queryKey,
+		},
+	}
+}
+
+// GetMetricTypes returns the available Synthetics metric types.
+// When an API key is configured, the metric types are also returned for every monitor and location visible to it.
+// Listing monitors requires an Admin API key, if it fails only the generic metric types are returned.
+func (s *Synthetics) GetMetricTypes(cfg plugin.Config) ([]plugin.Metric, error) {
+	ns := plugin.NewNamespace("inteleon", "newrelic", "synthetics")
+
+	metrics, err := metricTypes(ns, SyntheticsMetrics)
+	if err != nil {
+		return metrics, err
+	}
+
+	if _, err := cfg.GetString("api_key"); err != nil {
+		// No API key, no monitors to discover.
+		return metrics, nil
+	}
+
+	monitors, err := s.SyntheticsClient.GetMonitors()
+	if err != nil {
+		// Monitors not discoverable with this API key, the generic metric types still work.
+		return metrics, nil
+	}
+
+	for _, monitor := range monitors {
+		for _, location := range monitor.Locations {
+			monitorMetrics, err := metricTypes(
+				ns,
+				withNamespaceValue(withNamespaceValue(SyntheticsMetrics, "monitor_id", monitor.ID), "location", location),
+			)
+			if err != nil {
+				return metrics, err
+			}
+
+			for i := range monitorMetrics {
+				metrics = append(metrics, monitorMetrics[i])
+			}
+		}
+	}
+
+	return metrics, nil
+}
+
+// CollectMetrics fetches the requested Synthetics metrics and returns them.
+func (s *Synthetics) CollectMetrics(metrics []plugin.Metric) ([]plugin.Metric, error) {
+	collectedMetrics := []plugin.Metric{}
+
+	if len(metrics) == 0 {
+		return collectedMetrics, fmt.Errorf("List of metrics is empty")
+	}
+
+	var monitors map[string]SyntheticsMonitor
+	results := map[int][]SyntheticsResult{}
+	for i, m := range metrics {
+		if m.Namespace.Element(2).Value != "synthetics" {
+			continue
+		}
+
+		if monitors == nil {
+			// Monitors missing, fetching... They're only used to resolve monitor names and tag the results, listing
+			// them requires an Admin API key, without it the results are reported by monitor id only.
+			monitors = map[string]SyntheticsMonitor{}
+
+			if fetchedMonitors, err := s.SyntheticsClient.GetMonitors(); err == nil {
+				for _, monitor := range fetchedMonitors {
+					monitors[monitor.ID] = monitor
+				}
+			}
+		}
+
+		minutes := SyntheticsDefaultMinutes
+		if relativeMin := m.Namespace.Element(7).Value; relativeMin != "*" {
+			relativeMinInt, err := strconv.Atoi(relativeMin)
+			if err != nil {
+				return collectedMetrics, err
+			}
+
+			minutes = relativeMinInt
+		}
+
+		if _, ok := results[minutes]; !ok {
+			// Results missing, fetching...
+			fetchedResults, err := s.SyntheticsClient.GetMonitorResults(minutes)
+			if err != nil {
+				return collectedMetrics, err
+			}
+
+			results[minutes] = fetchedResults
+		}
+
+		monitorID := m.Namespace.Element(4).Value
+		location := m.Namespace.Element(6).Value
+		for _, result := range results[minutes] {
+			monitor, known := monitors[result.MonitorID]
+			if monitorID != "*" && monitorID != result.MonitorID && (!known || monitorID != monitor.Name) {
+				continue
+			}
+
+			if location != "*" && location != result.Location {
+				continue
+			}
+
+			resultMetric := metrics[i]
+			if monitorID == "*" {
+				resultMetric = withElementValue(resultMetric, 4, result.MonitorID)
+			}
+
+			if location == "*" {
+				resultMetric = withElementValue(resultMetric, 6, result.Location)
+			}
+
+			tags := map[string]string{
+				"monitor_id": result.MonitorID,
+				"location":   result.Location,
+			}
+
+			if known {
+				tags["monitor_name"] = monitor.Name
+				tags["monitor_type"] = monitor.Type
+			}
+
+			populatedMetric, err := populateMetric(
+				resultMetric,
+				map[string]interface{}{
+					"Success":  result.Success,
+					"Duration": result.Duration,
+					"Failures": result.Failures,
+				},
+				tags,
+			)
+			if err != nil {
+				// Metric not found, skip reporting it and continue execution.
+				continue
+			}
+
+			collectedMetrics = append(collectedMetrics, populatedMetric)
+		}
+	}
+
+	return collectedMetrics, nil
+}
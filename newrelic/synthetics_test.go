@@ -0,0 +1,263 @@
+package newrelic_test
+
+import (
+	"fmt"
+	"github.com/inteleon/snap-plugin-collector-newrelic/newrelic"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"strings"
+	"testing"
+)
+
+type syntheticsClientTestImpl struct {
+	monitorsCalls  int
+	resultsMinutes []int
+}
+
+func (sc *syntheticsClientTestImpl) GetMonitors() ([]newrelic.SyntheticsMonitor, error) {
+	sc.monitorsCalls++
+
+	return []newrelic.SyntheticsMonitor{
+		{
+			ID:        "aaaa-1111",
+			Name:      "homepage",
+			Type:      "BROWSER",
+			Locations: []string{"AWS_EU_WEST_1", "AWS_US_EAST_1"},
+		},
+		{
+			ID:        "bbbb-2222",
+			Name:      "api-ping",
+			Type:      "SIMPLE",
+			Locations: []string{"AWS_EU_WEST_1"},
+		},
+	}, nil
+}
+
+func (sc *syntheticsClientTestImpl) GetMonitorResults(minutes int) ([]newrelic.SyntheticsResult, error) {
+	sc.resultsMinutes = append(sc.resultsMinutes, minutes)
+
+	return []newrelic.SyntheticsResult{
+		{
+			MonitorID: "aaaa-1111",
+			Location:  "AWS_EU_WEST_1",
+			Success:   true,
+			Duration:  1337.5,
+		},
+		{
+			MonitorID: "aaaa-1111",
+			Location:  "AWS_US_EAST_1",
+			Success:   false,
+			Duration:  3000,
+			Failures:  2,
+		},
+		{
+			MonitorID: "bbbb-2222",
+			Location:  "AWS_EU_WEST_1",
+			Success:   true,
+			Duration:  12.34,
+		},
+	}, nil
+}
+
+func TestGetSyntheticsMetricTypesSuccess(t *testing.T) {
+	s := &newrelic.Synthetics{}
+
+	metrics, err := s.GetMetricTypes(plugin.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLen := len(newrelic.SyntheticsMetrics)
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	for i, m := range metrics {
+		expectedNS := fmt.Sprintf("inteleon/newrelic/synthetics/%s", strings.Join(newrelic.SyntheticsMetrics[i].Namespace.Strings(), "/"))
+		ns := strings.Join(m.Namespace.Strings(), "/")
+
+		if ns != expectedNS {
+			t.Fatal("expected", expectedNS, "got", ns)
+		}
+	}
+}
+
+func TestGetSyntheticsMetricTypesWithMonitors(t *testing.T) {
+	s := &newrelic.Synthetics{
+		SyntheticsClient: &syntheticsClientTestImpl{},
+	}
+
+	metrics, err := s.GetMetricTypes(plugin.Config{"api_key": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Static metrics plus one set per monitor location.
+	expectedLen := len(newrelic.SyntheticsMetrics) * 4
+	if len(metrics) != expectedLen {
+		t.Fatal("expected", expectedLen, "got", len(metrics))
+	}
+
+	m := metrics[len(newrelic.SyntheticsMetrics)]
+	if m.Namespace.Element(4).Value != "aaaa-1111" {
+		t.Fatal("expected", "aaaa-1111", "got", m.Namespace.Element(4).Value)
+	}
+
+	if m.Namespace.Element(6).Value != "AWS_EU_WEST_1" {
+		t.Fatal("expected", "AWS_EU_WEST_1", "got", m.Namespace.Element(6).Value)
+	}
+}
+
+type failingSyntheticsClientTestImpl struct {
+	syntheticsClientTestImpl
+}
+
+func (sc *failingSyntheticsClientTestImpl) GetMonitors() ([]newrelic.SyntheticsMonitor, error) {
+	return nil, fmt.Errorf("New Relic API request failed with status 403: admin key required")
+}
+
+func TestGetSyntheticsMetricTypesMonitorsFailure(t *testing.T) {
+	s := &newrelic.Synthetics{
+		SyntheticsClient: &failingSyntheticsClientTestImpl{},
+	}
+
+	metrics, err := s.GetMetricTypes(plugin.Config{"api_key": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the generic metric types are returned when the monitors can't be listed.
+	if len(metrics) != len(newrelic.SyntheticsMetrics) {
+		t.Fatal("expected", len(newrelic.SyntheticsMetrics), "got", len(metrics))
+	}
+}
+
+func TestCollectSyntheticsMetricsSuccess(t *testing.T) {
+	syntheticsClient := &syntheticsClientTestImpl{}
+
+	s := &newrelic.Synthetics{
+		SyntheticsClient: syntheticsClient,
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "synthetics", "monitor", "*", "location", "*", "5", "success"),
+			Tags: map[string]string{
+				"Type": "result",
+				"Path": "Success",
+				"Unit": "bool",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "synthetics", "monitor", "homepage", "location", "AWS_US_EAST_1", "5", "failures"),
+			Tags: map[string]string{
+				"Type": "result",
+				"Path": "Failures",
+				"Unit": "int",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "synthetics", "monitor", "bbbb-2222", "location", "*", "*", "duration"),
+			Tags: map[string]string{
+				"Type": "result",
+				"Path": "Duration",
+				"Unit": "float",
+			},
+		},
+	}
+
+	ret, err := s.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ret) != 5 {
+		t.Fatal("expected", 5, "got", len(ret))
+	}
+
+	expected := []struct {
+		monitorID string
+		location  string
+		data      interface{}
+	}{
+		{"aaaa-1111", "AWS_EU_WEST_1", true},
+		{"aaaa-1111", "AWS_US_EAST_1", false},
+		{"bbbb-2222", "AWS_EU_WEST_1", true},
+		{"homepage", "AWS_US_EAST_1", 2},
+		{"bbbb-2222", "AWS_EU_WEST_1", 12.34},
+	}
+	for i, e := range expected {
+		if ret[i].Namespace.Element(4).Value != e.monitorID {
+			t.Fatal("expected", e.monitorID, "got", ret[i].Namespace.Element(4).Value)
+		}
+
+		if ret[i].Namespace.Element(6).Value != e.location {
+			t.Fatal("expected", e.location, "got", ret[i].Namespace.Element(6).Value)
+		}
+
+		if ret[i].Data != e.data {
+			t.Fatal("expected", e.data, "got", ret[i].Data)
+		}
+	}
+
+	if ret[3].Tags["monitor_name"] != "homepage" {
+		t.Fatal("expected", "homepage", "got", ret[3].Tags["monitor_name"])
+	}
+
+	if syntheticsClient.monitorsCalls != 1 {
+		t.Fatal("expected", 1, "got", syntheticsClient.monitorsCalls)
+	}
+
+	expectedMinutes := fmt.Sprint([]int{5, newrelic.SyntheticsDefaultMinutes})
+	if fmt.Sprint(syntheticsClient.resultsMinutes) != expectedMinutes {
+		t.Fatal("expected", expectedMinutes, "got", syntheticsClient.resultsMinutes)
+	}
+}
+
+func TestCollectSyntheticsMetricsMonitorsFailure(t *testing.T) {
+	s := &newrelic.Synthetics{
+		SyntheticsClient: &failingSyntheticsClientTestImpl{},
+	}
+
+	metrics := []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "synthetics", "monitor", "*", "location", "AWS_EU_WEST_1", "5", "success"),
+			Tags: map[string]string{
+				"Type": "result",
+				"Path": "Success",
+				"Unit": "bool",
+			},
+		},
+		{
+			Namespace: plugin.NewNamespace("inteleon", "newrelic", "synthetics", "monitor", "homepage", "location", "*", "5", "failures"),
+			Tags: map[string]string{
+				"Type": "result",
+				"Path": "Failures",
+				"Unit": "int",
+			},
+		},
+	}
+
+	ret, err := s.CollectMetrics(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the monitors the results are reported by monitor id, monitor names can't be resolved.
+	if len(ret) != 2 {
+		t.Fatal("expected", 2, "got", len(ret))
+	}
+
+	for i, monitorID := range []string{"aaaa-1111", "bbbb-2222"} {
+		if ret[i].Namespace.Element(4).Value != monitorID {
+			t.Fatal("expected", monitorID, "got", ret[i].Namespace.Element(4).Value)
+		}
+
+		if ret[i].Tags["monitor_id"] != monitorID {
+			t.Fatal("expected", monitorID, "got", ret[i].Tags["monitor_id"])
+		}
+
+		if _, ok := ret[i].Tags["monitor_name"]; ok {
+			t.Fatal("expected", "no monitor_name tag", "got", ret[i].Tags["monitor_name"])
+		}
+	}
+}